@@ -0,0 +1,110 @@
+package main
+
+import (
+	"image/color"
+
+	"github.com/faiface/pixel"
+	"github.com/faiface/pixel/imdraw"
+	"github.com/mitas1/physical-based-animations/sim"
+)
+
+// attractorHandleRadius is radius of the draggable handle of an attractor in pixels
+const attractorHandleRadius = 10.0
+
+// AttractorEditor represents attractors placed in the scene and parameters of the selected one
+// which are controlled by gui
+type AttractorEditor struct {
+	attractors []*sim.Attractor
+	selected   *sim.Attractor
+	strength   *sim.Parameter // in N*m^{2} or N*m
+	softening  *sim.Parameter // in m
+	cutoff     *sim.Parameter // in m
+	falloff    sim.Falloff
+}
+
+// Add places a new attractor to the given position in pixels and selects it
+func (editor *AttractorEditor) Add(position pixel.Vec) *sim.Attractor {
+	attractor := &sim.Attractor{Position: position}
+	editor.attractors = append(editor.attractors, attractor)
+	editor.selected = attractor
+	editor.Apply()
+	return attractor
+}
+
+// Remove removes an attractor from the scene
+func (editor *AttractorEditor) Remove(attractor *sim.Attractor) {
+	for i, a := range editor.attractors {
+		if a == attractor {
+			editor.attractors = append(editor.attractors[:i], editor.attractors[i+1:]...)
+			break
+		}
+	}
+	if editor.selected == attractor {
+		editor.selected = nil
+	}
+}
+
+// Select makes attractor the one controlled by gui and loads it's parameters
+func (editor *AttractorEditor) Select(attractor *sim.Attractor) {
+	editor.selected = attractor
+	editor.strength.Value = attractor.Strength
+	editor.softening.Value = attractor.Softening
+	editor.cutoff.Value = attractor.Cutoff
+	editor.falloff = attractor.Falloff
+}
+
+// Apply copies parameters controlled by gui to the selected attractor
+func (editor *AttractorEditor) Apply() {
+	if editor.selected == nil {
+		return
+	}
+	editor.selected.Strength = editor.strength.Value
+	editor.selected.Softening = editor.softening.Value
+	editor.selected.Cutoff = editor.cutoff.Value
+	editor.selected.Falloff = editor.falloff
+}
+
+// AttractorAt returns attractor whose handle contains the given position in pixels
+func (editor *AttractorEditor) AttractorAt(position pixel.Vec) *sim.Attractor {
+	for i := len(editor.attractors) - 1; i >= 0; i-- {
+		if editor.attractors[i].Position.To(position).Len() <= attractorHandleRadius {
+			return editor.attractors[i]
+		}
+	}
+	return nil
+}
+
+func (editor *AttractorEditor) draw(imd *imdraw.IMDraw, offset pixel.Vec) {
+	for _, attractor := range editor.attractors {
+		drawAttractor(imd, attractor, attractor.Position.Add(offset), attractor == editor.selected)
+	}
+}
+
+// drawAttractor draws handle of the attractor, attractors are blue and repulsors red. Cutoff
+// radius is drawn as a faint ring
+func drawAttractor(
+	imd *imdraw.IMDraw,
+	attractor *sim.Attractor,
+	position pixel.Vec,
+	selected bool) {
+	fill := color.RGBA{40, 90, 200, 140}
+	if attractor.Strength < 0 {
+		fill = color.RGBA{200, 50, 40, 140}
+	}
+
+	imd.Color = fill
+	imd.Push(position)
+	imd.Circle(attractorHandleRadius, 0)
+
+	if selected {
+		imd.Color = color.RGBA{0, 0, 0, 160}
+		imd.Push(position)
+		imd.Circle(attractorHandleRadius+3, 2)
+	}
+
+	if attractor.Cutoff > 0 {
+		imd.Color = color.RGBA{fill.R, fill.G, fill.B, 40}
+		imd.Push(position)
+		imd.Circle(attractor.Cutoff*sim.PixelsPerMeter, 1)
+	}
+}
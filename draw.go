@@ -0,0 +1,235 @@
+package main
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/faiface/pixel"
+	"github.com/faiface/pixel/imdraw"
+	"github.com/mitas1/physical-based-animations/sim"
+)
+
+var (
+	colliderFill    = color.RGBA{0, 0, 0, 30}
+	colliderOutline = color.RGBA{0, 0, 0, 50}
+)
+
+// drawParticles draws particles interpolated between the last two steps by alpha. Particles are
+// coloured by density of the fluid when fluid is enabled
+func drawParticles(
+	particles *sim.Particles,
+	sprite *pixel.Sprite,
+	batch *pixel.Batch,
+	cam pixel.Matrix,
+	alpha float64,
+	fluid *sim.Fluid) {
+	for i := 0; i < particles.Len(); i++ {
+		matrix := spriteMatrix(cam, particles.Interpolated(i, alpha), particles.Radius(i))
+		if fluid.Enabled {
+			sprite.DrawColorMask(batch, matrix, fluidColor(fluid, particles.Density(i)))
+		} else {
+			sprite.Draw(batch, matrix)
+		}
+	}
+}
+
+// drawNBody draws particles of n-body system interpolated between the last two steps by alpha
+func drawNBody(
+	system *sim.NBodySystem,
+	sprite *pixel.Sprite,
+	batch *pixel.Batch,
+	cam pixel.Matrix,
+	alpha float64) {
+	particles := system.Particles()
+	for i := range particles {
+		sprite.Draw(batch, spriteMatrix(cam, particles[i].Interpolated(alpha), particles[i].Radius))
+	}
+}
+
+// spriteMatrix returns matrix placing particle sprite at position in pixels, the sprite is scaled
+// to radius in m
+func spriteMatrix(cam pixel.Matrix, position pixel.Vec, radius float64) pixel.Matrix {
+	return pixel.IM.Scaled(pixel.ZV, radius/sim.ParticleRadius).Moved(cam.Unproject(position))
+}
+
+// fluidColor returns colour mask of a particle with density in kg*m^{-2}, dense fluid is darker
+// than the spray
+func fluidColor(fluid *sim.Fluid, density float64) pixel.RGBA {
+	t := math.Min(math.Max((density/fluid.RestDensity.Value-0.5)/1.5, 0), 1)
+	return pixel.RGB(1-0.7*t, 1-0.55*t, 1-0.15*t)
+}
+
+func drawScene(imd *imdraw.IMDraw, scene *sim.Scene, offset pixel.Vec) {
+	for _, collider := range scene.Colliders() {
+		drawCollider(imd, collider, offset)
+	}
+}
+
+func drawCollider(imd *imdraw.IMDraw, collider sim.Collider, offset pixel.Vec) {
+	switch collider := collider.(type) {
+	case *sim.Circle:
+		position := collider.Position.Add(offset)
+		imd.Color = colliderFill
+		imd.Push(position)
+		imd.Circle(collider.Radius, 0)
+		imd.Color = colliderOutline
+		imd.Push(position)
+		imd.Circle(collider.Radius, 1)
+	case *sim.ConvexPolygon:
+		drawPolygon(imd, collider.Vertices, offset)
+	case *sim.OrientedBox:
+		drawPolygon(imd, collider.Vertices(), offset)
+	case *sim.Capsule:
+		a, b := collider.A.Add(offset), collider.B.Add(offset)
+
+		imd.Color = colliderFill
+		imd.EndShape = imdraw.RoundEndShape
+		imd.Push(a, b)
+		imd.Line(2 * collider.Radius)
+		imd.EndShape = imdraw.NoEndShape
+
+		// outline consists of two sides and two half circles
+		side := b.Sub(a).Normal().Unit().Scaled(collider.Radius)
+		angle := b.Sub(a).Angle()
+		imd.Color = colliderOutline
+		imd.Push(a.Add(side), b.Add(side))
+		imd.Line(1)
+		imd.Push(a.Sub(side), b.Sub(side))
+		imd.Line(1)
+		imd.Push(b)
+		imd.CircleArc(collider.Radius, angle-math.Pi/2, angle+math.Pi/2, 1)
+		imd.Push(a)
+		imd.CircleArc(collider.Radius, angle+math.Pi/2, angle+3*math.Pi/2, 1)
+	case *sim.Segment:
+		imd.Color = colliderOutline
+		imd.Push(collider.A.Add(offset), collider.B.Add(offset))
+		imd.Line(2)
+	case *sim.AxisAlignedBox:
+		imd.Color = colliderFill
+		imd.Push(collider.Min.Add(offset), collider.Max.Add(offset))
+		imd.Rectangle(0)
+		imd.Color = colliderOutline
+		imd.Push(collider.Min.Add(offset), collider.Max.Add(offset))
+		imd.Rectangle(1)
+	}
+}
+
+func drawPolygon(imd *imdraw.IMDraw, vertices []pixel.Vec, offset pixel.Vec) {
+	for _, thickness := range []float64{0, 1} {
+		imd.Color = colliderFill
+		if thickness > 0 {
+			imd.Color = colliderOutline
+		}
+		for _, vertex := range vertices {
+			imd.Push(vertex.Add(offset))
+		}
+		imd.Polygon(thickness)
+	}
+}
+
+func drawMassSpring(imd *imdraw.IMDraw, body *sim.MassSpring, offset pixel.Vec, alpha float64) {
+	particles := body.Particles()
+	imd.Color = color.RGBA{60, 60, 60, 255}
+	for _, link := range body.Links() {
+		imd.Push(particles[link.A].Interpolated(alpha).Add(offset),
+			particles[link.B].Interpolated(alpha).Add(offset))
+		imd.Line(1)
+	}
+
+	for i := range particles {
+		imd.Color = color.RGBA{60, 60, 60, 255}
+		if body.Pinned(i) {
+			imd.Color = color.RGBA{200, 50, 40, 255}
+		}
+		imd.Push(particles[i].Interpolated(alpha).Add(offset))
+		imd.Circle(2.5, 0)
+	}
+}
+
+func drawPositionBasedBody(
+	imd *imdraw.IMDraw,
+	body *sim.PositionBasedBody,
+	offset pixel.Vec,
+	alpha float64) {
+	particles := body.Particles()
+	imd.Color = color.RGBA{40, 90, 200, 255}
+	for _, constraint := range body.Constraints() {
+		if distance, ok := constraint.(*sim.DistanceConstraint); ok {
+			imd.Push(particles[distance.A].Interpolated(alpha).Add(offset),
+				particles[distance.B].Interpolated(alpha).Add(offset))
+			imd.Line(1)
+		}
+	}
+
+	for i := range particles {
+		imd.Color = color.RGBA{40, 90, 200, 120}
+		if body.PinOf(i) != nil {
+			imd.Color = color.RGBA{200, 50, 40, 255}
+		}
+		imd.Push(particles[i].Interpolated(alpha).Add(offset))
+		imd.Circle(particles[i].Radius*sim.PixelsPerMeter, 0)
+	}
+}
+
+func drawRigidWorld(imd *imdraw.IMDraw, world *sim.RigidWorld, offset pixel.Vec, alpha float64) {
+	for _, body := range world.Bodies() {
+		fill := color.RGBA{90, 150, 90, 160}
+		if body.Mass <= 0 {
+			fill = color.RGBA{0, 0, 0, 60}
+		}
+		position, angle := body.Interpolated(alpha)
+
+		if body.IsCircle() {
+			imd.Color = fill
+			imd.Push(position.Add(offset))
+			imd.Circle(body.Radius, 0)
+			// radius shows rotation of the circle
+			imd.Color = color.RGBA{0, 0, 0, 160}
+			imd.Push(position.Add(offset),
+				position.Add(pixel.V(body.Radius, 0).Rotated(angle)).Add(offset))
+			imd.Line(1)
+			continue
+		}
+
+		imd.Color = fill
+		for _, vertex := range body.Vertices {
+			imd.Push(vertex.Rotated(angle).Add(position).Add(offset))
+		}
+		imd.Polygon(0)
+		imd.Color = color.RGBA{0, 0, 0, 160}
+		for _, vertex := range body.Vertices {
+			imd.Push(vertex.Rotated(angle).Add(position).Add(offset))
+		}
+		imd.Polygon(1)
+	}
+}
+
+// drawWind draws arrows of the wind velocity on a grid covering bounds in pixels
+func drawWind(imd *imdraw.IMDraw, wind *sim.Wind, bounds pixel.Rect, offset pixel.Vec) {
+	const (
+		spacing = 40.0 // in pixels
+		length  = 4.0  // in pixels per m*s^{-1}
+	)
+
+	imd.Color = color.RGBA{70, 110, 200, 120}
+	for x := bounds.Min.X + spacing/2; x < bounds.Max.X; x += spacing {
+		for y := bounds.Min.Y + spacing/2; y < bounds.Max.Y; y += spacing {
+			position := pixel.V(x, y)
+			arrow := wind.Velocity(position).Scaled(length)
+			if arrow.Len() < 1 {
+				continue
+			}
+
+			start := position.Add(offset)
+			end := start.Add(arrow)
+			head := arrow.Unit().Scaled(math.Min(6, arrow.Len()/2))
+
+			imd.Push(start, end)
+			imd.Line(1)
+			imd.Push(end, end.Sub(head.Rotated(math.Pi/6)))
+			imd.Line(1)
+			imd.Push(end, end.Sub(head.Rotated(-math.Pi/6)))
+			imd.Line(1)
+		}
+	}
+}
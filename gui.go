@@ -2,14 +2,22 @@ package main
 
 import (
 	"fmt"
+	"image/color"
+	"math"
+	"strings"
 	"time"
 
 	"github.com/faiface/pixel"
+	"github.com/faiface/pixel/imdraw"
 	"github.com/faiface/pixel/pixelgl"
 	"github.com/faiface/pixel/text"
+	"github.com/mitas1/physical-based-animations/sim"
 	"golang.org/x/image/colornames"
 )
 
+// allPages is a page of gui elements which are displayed regardless of the active page
+const allPages = -1
+
 // Button represents a gui button element
 type Button struct {
 	position           pixel.Vec
@@ -20,33 +28,47 @@ type Button struct {
 	sprite             *pixel.Sprite
 	spriteActive       *pixel.Sprite
 	isActive           bool
+	label              string     // buttons with label are rendered as text instead of sprite
+	labelWidget        *text.Text // widget the label is written to
+	page               int        // page of gui the button is displayed on
 }
 
 // Text represents parameters required to construct a struct object in gui
 type Text struct {
 	position pixel.Vec
-	text     *Parameter
+	text     *sim.Parameter
 	widget   *text.Text
 	format   string
+	page     int // page of gui the text is displayed on
 }
 
 // SliderWannabe represents abstract of slider that changes a given parameter
 type SliderWannabe struct {
 	y           float64
 	canvasWidth float64 // width of the canvas SliderWannabe is rendered do so the internal objects can be properly spaced
-	parameter   *Parameter
+	parameter   *sim.Parameter
 	format      string
 }
 
 // SwitchWannabe represents abstract of switch that can switch between various position integrator
-// methods
+// methods. Buttons of the switch are built from the registry of integrators
 type SwitchWannabe struct {
 	y                  float64
 	canvasWidth        float64 // width of the canvas SwitchWannabe is redered to so the internal objects can be properly spaced
-	positionIntegrator PositionIntegrationMethod
+	positionIntegrator sim.Integrator
 	buttons            []*Button
 }
 
+// ChoiceWannabe represents abstract of switch that chooses one of the given options
+type ChoiceWannabe struct {
+	y           float64
+	canvasWidth float64 // width of the canvas ChoiceWannabe is rendered to so the internal objects can be properly spaced
+	options     []string
+	onChoice    func(index int)
+	buttons     []*Button
+	selected    int // index of the chosen option
+}
+
 // GUI represents an attributes of gui
 type GUI struct {
 	atlas       *text.Atlas
@@ -59,6 +81,9 @@ type GUI struct {
 	spritesheet pixel.Picture
 	canvas      *pixelgl.Canvas
 	textScale   float64
+	imd         *imdraw.IMDraw // backgrounds of the label buttons
+	tabs        []*Button      // buttons switching between pages of gui
+	page        int            // active page of gui
 }
 
 // HandledOptions define options which may be controlled by gui elements
@@ -98,7 +123,8 @@ func (gui *GUI) MainLoop() {
 func (gui *GUI) handleClick(x, y float64) {
 	y = winHeight - y
 	for _, widget := range gui.widgets {
-		if isInsideBoundingBox(x, y, widget.bounds, widget.position) {
+		if gui.isOnActivePage(widget.page) &&
+			isInsideBoundingBox(x, y, widget.bounds, widget.position) {
 			widget.onClick(gui.state)
 		}
 	}
@@ -115,16 +141,25 @@ func (gui *GUI) SetMatrix(matrix pixel.Matrix) {
 	gui.matrix = pixel.IM.Moved(pixel.V(-x, y))
 }
 
-// NewButton creates a new button element
+// NewButton creates a new button element. The button is placed to the last created page of gui or
+// to all pages when no page was created yet
 func (gui *GUI) NewButton(button *Button) {
-	button.sprite = pixel.NewSprite(gui.spritesheet, button.croppingArea)
-	button.spriteActive = pixel.NewSprite(gui.spritesheet, button.croppingAreaActive)
+	button.page = len(gui.tabs) - 1
+
+	if button.label != "" {
+		button.labelWidget = text.New(pixel.V(0, 0), gui.atlas)
+	} else {
+		button.sprite = pixel.NewSprite(gui.spritesheet, button.croppingArea)
+		button.spriteActive = pixel.NewSprite(gui.spritesheet, button.croppingAreaActive)
+	}
 
 	gui.widgets = append(gui.widgets, button)
 }
 
-// NewText adds new text to the gui
+// NewText adds new text to the gui. The text is placed to the last created page of gui or to all
+// pages when no page was created yet
 func (gui *GUI) NewText(t Text) {
+	t.page = len(gui.tabs) - 1
 	gui.texts = append(gui.texts, t)
 }
 
@@ -135,7 +170,7 @@ func (gui *GUI) NewSliderWannabe(slider SliderWannabe) {
 		position:     pixel.V(10, slider.y),
 		croppingArea: pixel.R(60, 360, 120, 420),
 		bounds:       pixel.R(0, 0, 60, 60),
-		onClick:      slider.parameter.handleMinus,
+		onClick:      handleMinus(slider.parameter),
 	}
 
 	gui.NewButton(&minusButton)
@@ -146,7 +181,7 @@ func (gui *GUI) NewSliderWannabe(slider SliderWannabe) {
 		position:     pixel.V(slider.canvasWidth-60-10, slider.y),
 		croppingArea: pixel.R(0, 360, 60, 420),
 		bounds:       pixel.R(0, 0, 60, 60),
-		onClick:      slider.parameter.handlePlus,
+		onClick:      handlePlus(slider.parameter),
 	}
 
 	gui.NewButton(&plusButton)
@@ -165,41 +200,112 @@ func (gui *GUI) NewSliderWannabe(slider SliderWannabe) {
 	gui.NewText(textWidget)
 }
 
-// NewSwitchWannabe creates a switch that consists of three buttons
+// NewPage creates a new page of gui with a tab button that activates it. Tabs are laid out in rows
+// of four from the bottom of the rendering canvas. All gui elements created after the page belong
+// to it
+func (gui *GUI) NewPage(name string, canvasWidth float64) {
+	const (
+		columns    = 4
+		tabHeight  = 36.0
+		tabSpacing = 6.0
+	)
+	tabWidth := (canvasWidth - 2*10 - (columns-1)*tabSpacing) / columns
+
+	index := len(gui.tabs)
+	tab := &Button{
+		position: pixel.V(
+			10+float64(index%columns)*(tabWidth+tabSpacing),
+			winHeight-10-tabHeight-float64(index/columns)*(tabHeight+tabSpacing),
+		),
+		bounds: pixel.R(0, 0, tabWidth, tabHeight),
+		label:  strings.ToUpper(name),
+		onClick: func(state *HandledOptions) {
+			gui.handlePage(index)
+		},
+	}
+
+	gui.NewButton(tab)
+	tab.page = allPages
+	gui.tabs = append(gui.tabs, tab)
+	tab.isActive = index == gui.page
+}
+
+// NewSwitchWannabe creates a switch that consists of one button per registered integrator. Buttons
+// are laid out in two columns
 func (gui *GUI) NewSwitchWannabe(sw *SwitchWannabe) {
-	sw.buttons = append(sw.buttons, &Button{
-		position:           pixel.V((sw.canvasWidth-230)/2, sw.y),
-		croppingArea:       pixel.R(0, 240, 230, 300),
-		croppingAreaActive: pixel.R(0, 300, 230, 360),
-		bounds:             pixel.R(0, 0, 230, 60),
-		onClick:            sw.handleExplicitEuler,
-	})
-
-	sw.buttons = append(sw.buttons, &Button{
-		position:           pixel.V((sw.canvasWidth-267)/2, sw.y+80),
-		croppingArea:       pixel.R(0, 120, 267, 180),
-		croppingAreaActive: pixel.R(0, 180, 267, 240),
-		bounds:             pixel.R(0, 0, 267, 60),
-		onClick:            sw.handleMidpoint,
-	})
-
-	sw.buttons = append(sw.buttons, &Button{
-		position:           pixel.V((sw.canvasWidth-125)/2, sw.y+160),
-		croppingArea:       pixel.R(0, 0, 125, 60),
-		croppingAreaActive: pixel.R(0, 60, 125, 120),
-		bounds:             pixel.R(0, 0, 125, 60),
-		onClick:            sw.handleVerlet,
-	})
-
-	for _, button := range sw.buttons {
+	var labels []string
+	for _, integrator := range sim.Integrators() {
+		labels = append(labels, strings.ToUpper(integrator.Name()))
+	}
+
+	sw.buttons = gui.newLabelButtons(sw.y, sw.canvasWidth, 2, labels, sw.handleIntegrator)
+}
+
+// NewChoiceWannabe creates a switch that consists of one button per option. Buttons are laid out
+// in up to three columns
+func (gui *GUI) NewChoiceWannabe(choice *ChoiceWannabe) {
+	columns := len(choice.options)
+	if columns > 3 {
+		columns = 3
+	}
+
+	var labels []string
+	for _, option := range choice.options {
+		labels = append(labels, strings.ToUpper(option))
+	}
+
+	choice.buttons = gui.newLabelButtons(
+		choice.y, choice.canvasWidth, columns, labels, choice.handleChoice)
+}
+
+// newLabelButtons creates buttons with given labels laid out in a grid of given number of columns
+// starting at y and calls onClick with the index of the clicked button
+func (gui *GUI) newLabelButtons(
+	y float64,
+	canvasWidth float64,
+	columns int,
+	labels []string,
+	onClick func(index int)) []*Button {
+	const (
+		buttonHeight  = 36.0
+		buttonSpacing = 6.0
+	)
+	// buttons are placed 10 pixels from the edges of the rendering canvas
+	buttonWidth := (canvasWidth - 2*10 - float64(columns-1)*buttonSpacing) / float64(columns)
+
+	var buttons []*Button
+	for i, label := range labels {
+		index := i
+		button := &Button{
+			position: pixel.V(
+				10+float64(i%columns)*(buttonWidth+buttonSpacing),
+				y+float64(i/columns)*(buttonHeight+buttonSpacing),
+			),
+			bounds: pixel.R(0, 0, buttonWidth, buttonHeight),
+			label:  label,
+			onClick: func(state *HandledOptions) {
+				onClick(index)
+			},
+		}
 		gui.NewButton(button)
+		buttons = append(buttons, button)
 	}
+
+	return buttons
+}
+
+// isOnActivePage reports whether gui elements of a given page are displayed
+func (gui *GUI) isOnActivePage(page int) bool {
+	return page == allPages || page == gui.page
 }
 
 // Draw draws a gui to gui batch
 func (gui *GUI) Draw() {
 	gui.batch.Clear()
 	for _, widget := range gui.widgets {
+		if widget.label != "" || !gui.isOnActivePage(widget.page) {
+			continue
+		}
 		x0, y0 := widget.position.XY()
 		x1, y1 := widget.bounds.Center().XY()
 		if widget.isActive {
@@ -216,12 +322,15 @@ func (gui *GUI) DrawText(target pixel.Target) {
 	scale := 0.3
 
 	for _, t := range gui.texts {
+		if !gui.isOnActivePage(t.page) {
+			continue
+		}
 		x0, y0 := t.position.XY()
 
 		t.widget.Clear()
 		t.widget.Dot = t.widget.Orig
 
-		t.widget.WriteString(fmt.Sprintf(t.format, float64(t.text.value)))
+		t.widget.WriteString(fmt.Sprintf(t.format, float64(t.text.Value)))
 
 		t.widget.Draw(
 			gui.win,
@@ -235,3 +344,58 @@ func (gui *GUI) DrawText(target pixel.Target) {
 	}
 
 }
+
+// DrawLabels draws buttons with label to the target
+func (gui *GUI) DrawLabels(target pixel.Target) {
+	const scale = 0.3
+
+	if gui.imd == nil {
+		gui.imd = imdraw.New(nil)
+	}
+	gui.imd.Clear()
+
+	for _, widget := range gui.widgets {
+		if widget.label == "" || !gui.isOnActivePage(widget.page) {
+			continue
+		}
+		x0, y0 := widget.position.XY()
+		min := gui.matrix.Project(pixel.V(x0, -y0-widget.bounds.H()))
+		max := gui.matrix.Project(pixel.V(x0+widget.bounds.W(), -y0))
+
+		txt := widget.labelWidget
+		txt.Clear()
+		if widget.isActive {
+			gui.imd.Color = color.RGBA{92, 92, 92, 255}
+			gui.imd.Push(min, max)
+			gui.imd.Rectangle(0)
+			txt.Color = colornames.White
+		} else {
+			gui.imd.Color = color.RGBA{255, 255, 255, 255}
+			gui.imd.Push(min, max)
+			gui.imd.Rectangle(0)
+			gui.imd.Color = color.RGBA{182, 182, 182, 255}
+			gui.imd.Push(min, max)
+			gui.imd.Rectangle(1)
+			txt.Color = color.RGBA{92, 92, 92, 255}
+		}
+		txt.WriteString(widget.label)
+	}
+	gui.imd.Draw(target)
+
+	for _, widget := range gui.widgets {
+		if widget.label == "" || !gui.isOnActivePage(widget.page) {
+			continue
+		}
+		x0, y0 := widget.position.XY()
+		center := gui.matrix.Project(pixel.V(
+			x0+widget.bounds.W()/2,
+			-y0-widget.bounds.H()/2,
+		))
+
+		txt := widget.labelWidget
+		// label is shrinked so it fits into the button with 5 pixels padding on each side
+		textScale := math.Min(scale, (widget.bounds.W()-10)/txt.Bounds().W())
+		txt.Draw(target, pixel.IM.Scaled(pixel.ZV, textScale).Moved(
+			center.Sub(txt.Bounds().Center().Scaled(textScale))))
+	}
+}
@@ -1,5 +1,7 @@
 package main
 
+import "github.com/mitas1/physical-based-animations/sim"
+
 var state = HandledOptions{
 	paused:  false,
 	stopped: false,
@@ -18,36 +20,41 @@ func handleStopClick(state *HandledOptions) {
 	state.stopped = true
 }
 
-func (param *Parameter) handlePlus(state *HandledOptions) {
-	if param.value+param.step <= param.max {
-		param.value += param.step
+func handlePlus(param *sim.Parameter) func(state *HandledOptions) {
+	return func(state *HandledOptions) {
+		if param.Value+param.Step <= param.Max {
+			param.Value += param.Step
+		}
 	}
 }
 
-func (param *Parameter) handleMinus(state *HandledOptions) {
-	if param.value-param.step >= param.min {
-		param.value -= param.step
+func handleMinus(param *sim.Parameter) func(state *HandledOptions) {
+	return func(state *HandledOptions) {
+		if param.Value-param.Step >= param.Min {
+			param.Value -= param.Step
+		}
 	}
 }
 
-func (sw *SwitchWannabe) setActiveButton(index int) {
-	for _, button := range sw.buttons {
+func setActiveButton(buttons []*Button, index int) {
+	for _, button := range buttons {
 		button.isActive = false
 	}
-	sw.buttons[index].isActive = true
+	buttons[index].isActive = true
 }
 
-func (sw *SwitchWannabe) handleExplicitEuler(state *HandledOptions) {
-	sw.positionIntegrator = ExplicitEuler
-	sw.setActiveButton(0)
+func (sw *SwitchWannabe) handleIntegrator(index int) {
+	sw.positionIntegrator = sim.Integrators()[index]
+	setActiveButton(sw.buttons, index)
 }
 
-func (sw *SwitchWannabe) handleMidpoint(state *HandledOptions) {
-	sw.positionIntegrator = MidPoint
-	sw.setActiveButton(1)
+func (choice *ChoiceWannabe) handleChoice(index int) {
+	choice.selected = index
+	choice.onChoice(index)
+	setActiveButton(choice.buttons, index)
 }
 
-func (sw *SwitchWannabe) handleVerlet(state *HandledOptions) {
-	sw.positionIntegrator = Verlet
-	sw.setActiveButton(2)
+func (gui *GUI) handlePage(index int) {
+	gui.page = index
+	setActiveButton(gui.tabs, index)
 }
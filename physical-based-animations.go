@@ -1,9 +1,7 @@
 package main
 
 import (
-	"errors"
 	"fmt"
-	"math"
 	"math/rand"
 	"time"
 
@@ -14,68 +12,17 @@ import (
 
 	"github.com/faiface/pixel"
 	"github.com/faiface/pixel/pixelgl"
+
+	"github.com/mitas1/physical-based-animations/sim"
 )
 
 const (
 	winWidth  = 1024
 	winHeight = 768
+	// maxParticles is the most particles of the emitter alive at once
+	maxParticles = 100000
 )
 
-func updateParticles(
-	particles []Particle,
-	batch *pixel.Batch,
-	dt float64,
-	cam pixel.Matrix,
-	positionIntegrator PositionIntegrationMethod,
-	circle Circle) {
-	for i := 0; i < len(particles); i++ {
-		newPosition, err := getNewPosition(&particles[i], dt, positionIntegrator)
-		if err != nil {
-			fmt.Println(err.Error())
-		}
-
-		if circle.isPositionInside(newPosition) {
-			const coefficientOfRestitution = 0.5
-
-			unitNormalVector := newPosition.Sub(circle.position).Unit().Scaled(
-				circle.radius)
-			unitSpeed := particles[i].speed.Unit()
-
-			newPosition = unitNormalVector.Scaled(1.1).Add(circle.position)
-			newSpeed := particles[i].speed.Rotated(2 *
-				(math.Atan2(unitSpeed.Y, unitSpeed.X) -
-					math.Atan2(unitNormalVector.Y, unitNormalVector.X)))
-
-			particles[i].speed = newSpeed.Scaled(coefficientOfRestitution)
-
-			if positionIntegrator == Verlet {
-				particles[i].nextPosition = newPosition.Add(particles[i].speed.Scaled(PixelsPerMeter).Scaled(dt)).Add(
-					Gravity.Scaled(PixelsPerMeter).Scaled(dt * dt * 0.5))
-			}
-		}
-
-		particles[i].position = newPosition
-		particles[i].alive += dt
-		particles[i].sprite.Draw(batch, pixel.IM.Moved(cam.Unproject(particles[i].position)))
-	}
-}
-
-func getNewPosition(
-	particle *Particle,
-	dt float64,
-	mode PositionIntegrationMethod) (pixel.Vec, error) {
-	switch mode {
-	case ExplicitEuler:
-		return particle.ExplicitEulerIntegrator(dt), nil
-	case MidPoint:
-		return particle.ExplicitMidpointIntegrator(dt), nil
-	case Verlet:
-		return particle.VerletIntegrator(dt), nil
-	default:
-		return particle.position, errors.New("Unknown method of position integration")
-	}
-}
-
 func run() {
 	cfg := pixelgl.WindowConfig{
 		Title:  "Particle System",
@@ -113,55 +60,383 @@ func run() {
 		))
 
 	var (
-		camPos      = pixel.ZV
-		second      = time.Tick(time.Second)
-		frames      = 0
-		timeElapsed = 0.0
+		camPos = pixel.ZV
+		second = time.Tick(time.Second)
+		frames = 0
 	)
 
-	emitRate := Parameter{
-		value: 1000,
-		step:  100,
-		min:   0,
-		max:   2200,
+	emitRate := sim.Parameter{
+		Value: 1000,
+		Step:  100,
+		Min:   0,
+		Max:   2200,
+	}
+
+	emitAngle := sim.Parameter{
+		Value: 60,
+		Step:  5,
+		Min:   10,
+		Max:   360,
+	}
+
+	particleLife := sim.Parameter{
+		Value: 2,
+		Step:  0.1,
+		Min:   0.1,
+		Max:   4,
+	}
+
+	initialVelocity := sim.Parameter{
+		Value: 9.5,
+		Step:  0.5,
+		Min:   -2,
+		Max:   20,
+	}
+
+	// seed of emission, scattered obstacles and galaxies. Emission is seeded again and all bodies
+	// are built again when the simulation is stopped, so stopped runs are replayed from it
+	seed := sim.Parameter{
+		Value: 1,
+		Step:  1,
+		Min:   0,
+		Max:   1000,
+	}
+
+	particleMass := sim.Parameter{
+		Value: 0.05,
+		Step:  0.01,
+		Min:   0.01,
+		Max:   1,
+	}
+
+	dragCoefficient := sim.Parameter{
+		Value: 0.47,
+		Step:  0.05,
+		Min:   0,
+		Max:   2,
+	}
+
+	fluidViscosity := sim.Parameter{
+		Value: 0.5,
+		Step:  0.1,
+		Min:   0,
+		Max:   5,
+	}
+
+	fluidDensity := sim.Parameter{
+		Value: 10,
+		Step:  5,
+		Min:   0,
+		Max:   100,
+	}
+
+	dragMode := sim.NoDrag
+
+	windAngle := sim.Parameter{
+		Value: 0,
+		Step:  15,
+		Min:   -180,
+		Max:   180,
+	}
+
+	windSpeed := sim.Parameter{
+		Value: 2,
+		Step:  0.5,
+		Min:   0,
+		Max:   20,
+	}
+
+	windStrength := sim.Parameter{
+		Value: 3,
+		Step:  0.5,
+		Min:   0,
+		Max:   20,
+	}
+
+	windScale := sim.Parameter{
+		Value: 1,
+		Step:  0.1,
+		Min:   0.1,
+		Max:   5,
+	}
+
+	windEvolution := sim.Parameter{
+		Value: 0.5,
+		Step:  0.1,
+		Min:   0,
+		Max:   5,
+	}
+
+	wind := sim.Wind{
+		Angle:     &windAngle,
+		Speed:     &windSpeed,
+		Strength:  &windStrength,
+		Scale:     &windScale,
+		Evolution: &windEvolution,
+		Response:  0.5,
+	}
+
+	windOverlay := false
+
+	attractorStrength := sim.Parameter{
+		Value: 5,
+		Step:  0.5,
+		Min:   -20,
+		Max:   20,
+	}
+
+	attractorSoftening := sim.Parameter{
+		Value: 0.1,
+		Step:  0.05,
+		Min:   0,
+		Max:   1,
+	}
+
+	attractorCutoff := sim.Parameter{
+		Value: 0,
+		Step:  0.5,
+		Min:   0,
+		Max:   10,
+	}
+
+	particleRadius := sim.Parameter{
+		Value: sim.ParticleRadius,
+		Step:  0.005,
+		Min:   0.005,
+		Max:   0.1,
+	}
+
+	particleRestitution := sim.Parameter{
+		Value: 0.9,
+		Step:  0.1,
+		Min:   0,
+		Max:   1,
+	}
+
+	wallRestitution := sim.Parameter{
+		Value: 0.8,
+		Step:  0.1,
+		Min:   0,
+		Max:   1,
+	}
+
+	collisions := sim.ParticleCollisions{
+		Restitution: &particleRestitution,
+	}
+
+	linkStiffness := sim.Parameter{
+		Value: 500,
+		Step:  50,
+		Min:   50,
+		Max:   2000,
+	}
+
+	linkDamping := sim.Parameter{
+		Value: 0.5,
+		Step:  0.1,
+		Min:   0,
+		Max:   5,
+	}
+
+	smoothingLength := sim.Parameter{
+		Value: 0.1,
+		Step:  0.01,
+		Min:   0.03,
+		Max:   0.3,
+	}
+
+	restDensity := sim.Parameter{
+		Value: 50,
+		Step:  5,
+		Min:   5,
+		Max:   200,
 	}
 
-	emitAngle := Parameter{
-		value: 60,
-		step:  5,
-		min:   10,
-		max:   360,
+	fluidStiffness := sim.Parameter{
+		Value: 50,
+		Step:  5,
+		Min:   5,
+		Max:   200,
 	}
 
-	particleLife := Parameter{
-		value: 2,
-		step:  0.1,
-		min:   0.1,
-		max:   4,
+	liquidViscosity := sim.Parameter{
+		Value: 0.5,
+		Step:  0.1,
+		Min:   0,
+		Max:   3,
 	}
 
-	initialVelocity := Parameter{
-		value: 9.5,
-		step:  0.5,
-		min:   -2,
-		max:   20,
+	surfaceTension := sim.Parameter{
+		Value: 0.05,
+		Step:  0.01,
+		Min:   0,
+		Max:   1,
+	}
+
+	fluid := sim.Fluid{
+		SmoothingLength: &smoothingLength,
+		RestDensity:     &restDensity,
+		Stiffness:       &fluidStiffness,
+		Viscosity:       &liquidViscosity,
+		SurfaceTension:  &surfaceTension,
+	}
+
+	bodyCount := sim.Parameter{
+		Value: 1000,
+		Step:  100,
+		Min:   100,
+		Max:   5000,
+	}
+
+	openingAngle := sim.Parameter{
+		Value: 0.5,
+		Step:  0.05,
+		Min:   0,
+		Max:   1.5,
+	}
+
+	gravitySoftening := sim.Parameter{
+		Value: 0.05,
+		Step:  0.01,
+		Min:   0.01,
+		Max:   0.5,
+	}
+
+	var nBody *sim.NBodySystem
+
+	rigidFriction := sim.Parameter{
+		Value: 0.5,
+		Step:  0.05,
+		Min:   0,
+		Max:   1,
+	}
+
+	rigidRestitution := sim.Parameter{
+		Value: 0.2,
+		Step:  0.05,
+		Min:   0,
+		Max:   1,
+	}
+
+	rigidIterations := sim.Parameter{
+		Value: 10,
+		Step:  1,
+		Min:   1,
+		Max:   30,
+	}
+
+	var rigidWorld *sim.RigidWorld
+
+	var body *sim.MassSpring
+	draggedParticle := -1
+
+	solverIterations := sim.Parameter{
+		Value: 10,
+		Step:  1,
+		Min:   1,
+		Max:   50,
+	}
+
+	distanceCompliance := sim.Parameter{
+		Value: 0,
+		Step:  0.0005,
+		Min:   0,
+		Max:   0.01,
+	}
+
+	bendingCompliance := sim.Parameter{
+		Value: 0.05,
+		Step:  0.01,
+		Min:   0,
+		Max:   1,
+	}
+
+	var positionBasedBody *sim.PositionBasedBody
+	var draggedPin *sim.PinConstraint
+	draggedPinTemporary := false
+
+	attractors := AttractorEditor{
+		strength:  &attractorStrength,
+		softening: &attractorSoftening,
+		cutoff:    &attractorCutoff,
+		falloff:   sim.InverseSquare,
 	}
 
 	guiCanvasWidth := 320.0
 
-	particleSystem := ParticleSystem{
-		position: pixel.V((win.Bounds().W()+win.Bounds().Min.X+guiCanvasWidth)/2,
+	// the left edge of the area of particles is the edge of the gui
+	boundary := sim.Boundary{
+		Bounds: pixel.R(guiCanvasWidth, win.Bounds().Min.Y, win.Bounds().Max.X,
+			win.Bounds().Max.Y),
+		Restitution: &wallRestitution,
+	}
+
+	particleSystem := sim.ParticleSystem{
+		Position: pixel.V((win.Bounds().W()+win.Bounds().Min.X+guiCanvasWidth)/2,
 			win.Bounds().H()/4.0),
-		emitRate: &emitRate,
-		angle:    &emitAngle,
+		EmitRate:        &emitRate,
+		Angle:           &emitAngle,
+		Lifespan:        &particleLife,
+		Speed:           &initialVelocity,
+		Mass:            &particleMass,
+		Radius:          &particleRadius,
+		DragCoefficient: &dragCoefficient,
+		Capacity:        maxParticles,
+		Seed:            &seed,
+	}
+
+	particleSystem.AddForce(sim.UniformGravity{Acceleration: sim.Gravity})
+	particleSystem.AddForce(sim.Drag{
+		Mode:      &dragMode,
+		Viscosity: &fluidViscosity,
+		Density:   &fluidDensity,
+	})
+	particleSystem.AddForce(&wind)
+	particleSystem.AddForce(&fluid)
+
+	// scene of obstacles is built by the choices of the obstacle page
+	var scene *sim.Scene
+	obstacleShape := sim.CircleShape
+	obstacleLayout := 0
+	var draggedCollider sim.Collider
+	// material is set to the obstacle dragged last or to all obstacles when none was dragged
+	var selectedCollider sim.Collider
+
+	obstacleRestitution := sim.Parameter{
+		Value: 0.5,
+		Step:  0.05,
+		Min:   0,
+		Max:   1,
+	}
+
+	obstacleFriction := sim.Parameter{
+		Value: 0.2,
+		Step:  0.05,
+		Min:   0,
+		Max:   1,
 	}
+	lastMouse := pixel.ZV
 
-	circle := Circle{
-		position: pixel.V(412, 400),
-		radius:   50,
+	physicsRate := sim.Parameter{
+		Value: 240,
+		Step:  30,
+		Min:   30,
+		Max:   1200,
 	}
 
-	prevDt := 0.002
+	maxSubsteps := sim.Parameter{
+		Value: 8,
+		Step:  1,
+		Min:   1,
+		Max:   32,
+	}
+
+	// frames longer than a quarter of a second, e.g. while the window is dragged, are clamped
+	clock := sim.FixedStep{
+		Rate:        &physicsRate,
+		MaxSubsteps: &maxSubsteps,
+		MaxFrame:    0.25,
+	}
 
 	last := time.Now()
 
@@ -209,8 +484,10 @@ func run() {
 
 	gui.NewButton(stopButton)
 
+	gui.NewPage("Emitter", guiCanvasWidth)
+
 	emitRateSlider := SliderWannabe{
-		y:           360,
+		y:           100,
 		canvasWidth: guiCanvasWidth,
 		parameter:   &emitRate,
 		format:      "%.0f par/sec\n",
@@ -219,7 +496,7 @@ func run() {
 	gui.NewSliderWannabe(emitRateSlider)
 
 	emitAngleSlider := SliderWannabe{
-		y:           450,
+		y:           190,
 		canvasWidth: guiCanvasWidth,
 		parameter:   &emitAngle,
 		format:      "%.0f degrees\n",
@@ -228,7 +505,7 @@ func run() {
 	gui.NewSliderWannabe(emitAngleSlider)
 
 	particleLifeSlider := SliderWannabe{
-		y:           540,
+		y:           280,
 		canvasWidth: guiCanvasWidth,
 		parameter:   &particleLife,
 		format:      "lives %.1f s\n",
@@ -237,7 +514,7 @@ func run() {
 	gui.NewSliderWannabe(particleLifeSlider)
 
 	initialVelocitySlider := SliderWannabe{
-		y:           630,
+		y:           370,
 		canvasWidth: guiCanvasWidth,
 		parameter:   &initialVelocity,
 		format:      "%.1f m/s",
@@ -245,13 +522,573 @@ func run() {
 
 	gui.NewSliderWannabe(initialVelocitySlider)
 
+	seedSlider := SliderWannabe{
+		y:           460,
+		canvasWidth: guiCanvasWidth,
+		parameter:   &seed,
+		format:      "seed %.0f",
+	}
+
+	gui.NewSliderWannabe(seedSlider)
+
+	gui.NewPage("Integrator", guiCanvasWidth)
+
 	positionIntegratorSwitch := SwitchWannabe{
 		y:           100,
 		canvasWidth: guiCanvasWidth,
 	}
 
 	gui.NewSwitchWannabe(&positionIntegratorSwitch)
-	positionIntegratorSwitch.handleExplicitEuler(nil)
+	positionIntegratorSwitch.handleIntegrator(0)
+
+	physicsRateSlider := SliderWannabe{
+		y:           430,
+		canvasWidth: guiCanvasWidth,
+		parameter:   &physicsRate,
+		format:      "rate %.0f Hz",
+	}
+
+	gui.NewSliderWannabe(physicsRateSlider)
+
+	maxSubstepsSlider := SliderWannabe{
+		y:           520,
+		canvasWidth: guiCanvasWidth,
+		parameter:   &maxSubsteps,
+		format:      "substeps %.0f",
+	}
+
+	gui.NewSliderWannabe(maxSubstepsSlider)
+
+	gui.NewPage("Drag", guiCanvasWidth)
+
+	dragModeChoice := ChoiceWannabe{
+		y:           100,
+		canvasWidth: guiCanvasWidth,
+		options:     []string{"Off", "Stokes", "Newton"},
+		onChoice: func(index int) {
+			dragMode = sim.DragMode(index)
+		},
+	}
+
+	gui.NewChoiceWannabe(&dragModeChoice)
+	dragModeChoice.handleChoice(int(dragMode))
+
+	fluidViscositySlider := SliderWannabe{
+		y:           160,
+		canvasWidth: guiCanvasWidth,
+		parameter:   &fluidViscosity,
+		format:      "%.1f Pa s",
+	}
+
+	gui.NewSliderWannabe(fluidViscositySlider)
+
+	fluidDensitySlider := SliderWannabe{
+		y:           250,
+		canvasWidth: guiCanvasWidth,
+		parameter:   &fluidDensity,
+		format:      "%.0f kg/m3",
+	}
+
+	gui.NewSliderWannabe(fluidDensitySlider)
+
+	particleMassSlider := SliderWannabe{
+		y:           340,
+		canvasWidth: guiCanvasWidth,
+		parameter:   &particleMass,
+		format:      "%.2f kg",
+	}
+
+	gui.NewSliderWannabe(particleMassSlider)
+
+	dragCoefficientSlider := SliderWannabe{
+		y:           430,
+		canvasWidth: guiCanvasWidth,
+		parameter:   &dragCoefficient,
+		format:      "Cd %.2f",
+	}
+
+	gui.NewSliderWannabe(dragCoefficientSlider)
+
+	gui.NewPage("Wind", guiCanvasWidth)
+
+	windChoice := ChoiceWannabe{
+		y:           100,
+		canvasWidth: guiCanvasWidth,
+		options:     []string{"Off", "On", "Arrows"},
+		onChoice: func(index int) {
+			wind.Enabled = index > 0
+			windOverlay = index == 2
+		},
+	}
+
+	gui.NewChoiceWannabe(&windChoice)
+	windChoice.handleChoice(0)
+
+	windAngleSlider := SliderWannabe{
+		y:           160,
+		canvasWidth: guiCanvasWidth,
+		parameter:   &windAngle,
+		format:      "%.0f degrees",
+	}
+
+	gui.NewSliderWannabe(windAngleSlider)
+
+	windSpeedSlider := SliderWannabe{
+		y:           250,
+		canvasWidth: guiCanvasWidth,
+		parameter:   &windSpeed,
+		format:      "wind %.1f m/s",
+	}
+
+	gui.NewSliderWannabe(windSpeedSlider)
+
+	windStrengthSlider := SliderWannabe{
+		y:           340,
+		canvasWidth: guiCanvasWidth,
+		parameter:   &windStrength,
+		format:      "gusts %.1f m/s",
+	}
+
+	gui.NewSliderWannabe(windStrengthSlider)
+
+	windScaleSlider := SliderWannabe{
+		y:           430,
+		canvasWidth: guiCanvasWidth,
+		parameter:   &windScale,
+		format:      "eddies %.1f m",
+	}
+
+	gui.NewSliderWannabe(windScaleSlider)
+
+	windEvolutionSlider := SliderWannabe{
+		y:           520,
+		canvasWidth: guiCanvasWidth,
+		parameter:   &windEvolution,
+		format:      "changes %.1f /s",
+	}
+
+	gui.NewSliderWannabe(windEvolutionSlider)
+
+	gui.NewPage("Attract", guiCanvasWidth)
+
+	falloffChoice := ChoiceWannabe{
+		y:           100,
+		canvasWidth: guiCanvasWidth,
+		options:     []string{"1/r^2", "1/r"},
+		onChoice: func(index int) {
+			attractors.falloff = sim.Falloff(index)
+		},
+	}
+
+	gui.NewChoiceWannabe(&falloffChoice)
+	falloffChoice.handleChoice(int(attractors.falloff))
+
+	attractorStrengthSlider := SliderWannabe{
+		y:           160,
+		canvasWidth: guiCanvasWidth,
+		parameter:   &attractorStrength,
+		format:      "strength %.1f",
+	}
+
+	gui.NewSliderWannabe(attractorStrengthSlider)
+
+	attractorSofteningSlider := SliderWannabe{
+		y:           250,
+		canvasWidth: guiCanvasWidth,
+		parameter:   &attractorSoftening,
+		format:      "soft %.2f m",
+	}
+
+	gui.NewSliderWannabe(attractorSofteningSlider)
+
+	attractorCutoffSlider := SliderWannabe{
+		y:           340,
+		canvasWidth: guiCanvasWidth,
+		parameter:   &attractorCutoff,
+		format:      "cutoff %.1f m",
+	}
+
+	gui.NewSliderWannabe(attractorCutoffSlider)
+
+	gui.NewPage("Collide", guiCanvasWidth)
+
+	collisionsChoice := ChoiceWannabe{
+		y:           100,
+		canvasWidth: guiCanvasWidth,
+		options:     []string{"Off", "On"},
+		onChoice: func(index int) {
+			collisions.Enabled = index == 1
+		},
+	}
+
+	gui.NewChoiceWannabe(&collisionsChoice)
+	collisionsChoice.handleChoice(0)
+
+	particleRestitutionSlider := SliderWannabe{
+		y:           160,
+		canvasWidth: guiCanvasWidth,
+		parameter:   &particleRestitution,
+		format:      "restitution %.1f",
+	}
+
+	gui.NewSliderWannabe(particleRestitutionSlider)
+
+	particleRadiusSlider := SliderWannabe{
+		y:           250,
+		canvasWidth: guiCanvasWidth,
+		parameter:   &particleRadius,
+		format:      "radius %.3f m",
+	}
+
+	gui.NewSliderWannabe(particleRadiusSlider)
+
+	boundaryChoice := ChoiceWannabe{
+		y:           340,
+		canvasWidth: guiCanvasWidth,
+		options:     []string{"Open", "Walls", "Box", "Wrap"},
+		onChoice: func(index int) {
+			boundary.Mode = sim.BoundaryMode(index)
+		},
+	}
+
+	gui.NewChoiceWannabe(&boundaryChoice)
+	boundaryChoice.handleChoice(0)
+
+	wallRestitutionSlider := SliderWannabe{
+		y:           520,
+		canvasWidth: guiCanvasWidth,
+		parameter:   &wallRestitution,
+		format:      "walls %.1f",
+	}
+
+	gui.NewSliderWannabe(wallRestitutionSlider)
+
+	gui.NewPage("Obstacle", guiCanvasWidth)
+
+	// scene is rebuilt whenever the shape or the layout of obstacles is chosen
+	buildScene := func() {
+		room := pixel.R(guiCanvasWidth, win.Bounds().H()/3, win.Bounds().Max.X,
+			win.Bounds().H()*0.9)
+		switch obstacleLayout {
+		case 0:
+			scene = sim.NewScene(sim.NewCollider(obstacleShape, pixel.V(412, 400), 100))
+		case 1:
+			scene = sim.NewPegScene(room, obstacleShape)
+		case 2:
+			scene = sim.NewScatterScene(room, 30, rand.New(rand.NewSource(int64(seed.Value))))
+		}
+		draggedCollider = nil
+		selectedCollider = nil
+	}
+
+	obstacleShapeChoice := ChoiceWannabe{
+		y:           100,
+		canvasWidth: guiCanvasWidth,
+		options:     []string{"Circle", "Polygon", "Segment", "Box", "OBB", "Capsule"},
+		onChoice: func(index int) {
+			obstacleShape = sim.ColliderShape(index)
+			buildScene()
+		},
+	}
+
+	gui.NewChoiceWannabe(&obstacleShapeChoice)
+	obstacleShapeChoice.handleChoice(0)
+
+	obstacleLayoutChoice := ChoiceWannabe{
+		y:           220,
+		canvasWidth: guiCanvasWidth,
+		options:     []string{"Single", "Pegs", "Scatter"},
+		onChoice: func(index int) {
+			obstacleLayout = index
+			buildScene()
+		},
+	}
+
+	gui.NewChoiceWannabe(&obstacleLayoutChoice)
+	obstacleLayoutChoice.handleChoice(0)
+
+	obstacleRestitutionSlider := SliderWannabe{
+		y:           340,
+		canvasWidth: guiCanvasWidth,
+		parameter:   &obstacleRestitution,
+		format:      "restitution %.2f",
+	}
+
+	gui.NewSliderWannabe(obstacleRestitutionSlider)
+
+	obstacleFrictionSlider := SliderWannabe{
+		y:           430,
+		canvasWidth: guiCanvasWidth,
+		parameter:   &obstacleFriction,
+		format:      "friction %.2f",
+	}
+
+	gui.NewSliderWannabe(obstacleFrictionSlider)
+
+	gui.NewPage("Fluid", guiCanvasWidth)
+
+	fluidChoice := ChoiceWannabe{
+		y:           100,
+		canvasWidth: guiCanvasWidth,
+		options:     []string{"Off", "SPH"},
+		onChoice: func(index int) {
+			fluid.Enabled = index == 1
+		},
+	}
+
+	gui.NewChoiceWannabe(&fluidChoice)
+	fluidChoice.handleChoice(0)
+
+	smoothingLengthSlider := SliderWannabe{
+		y:           160,
+		canvasWidth: guiCanvasWidth,
+		parameter:   &smoothingLength,
+		format:      "kernel %.2f m",
+	}
+
+	gui.NewSliderWannabe(smoothingLengthSlider)
+
+	restDensitySlider := SliderWannabe{
+		y:           250,
+		canvasWidth: guiCanvasWidth,
+		parameter:   &restDensity,
+		format:      "rest %.0f kg/m2",
+	}
+
+	gui.NewSliderWannabe(restDensitySlider)
+
+	fluidStiffnessSlider := SliderWannabe{
+		y:           340,
+		canvasWidth: guiCanvasWidth,
+		parameter:   &fluidStiffness,
+		format:      "stiffness %.0f",
+	}
+
+	gui.NewSliderWannabe(fluidStiffnessSlider)
+
+	liquidViscositySlider := SliderWannabe{
+		y:           430,
+		canvasWidth: guiCanvasWidth,
+		parameter:   &liquidViscosity,
+		format:      "viscosity %.1f",
+	}
+
+	gui.NewSliderWannabe(liquidViscositySlider)
+
+	surfaceTensionSlider := SliderWannabe{
+		y:           520,
+		canvasWidth: guiCanvasWidth,
+		parameter:   &surfaceTension,
+		format:      "tension %.2f N",
+	}
+
+	gui.NewSliderWannabe(surfaceTensionSlider)
+
+	gui.NewPage("N-body", guiCanvasWidth)
+
+	nBodyChoice := ChoiceWannabe{
+		y:           100,
+		canvasWidth: guiCanvasWidth,
+		options:     []string{"Off", "Galaxy"},
+		onChoice: func(index int) {
+			nBody = nil
+			if index == 1 {
+				nBody = sim.NewGalaxy(
+					pixel.V((win.Bounds().W()+guiCanvasWidth)/2, win.Bounds().H()/2),
+					int(bodyCount.Value), 2.5, 100, 10, &openingAngle, &gravitySoftening,
+					rand.New(rand.NewSource(int64(seed.Value))))
+			}
+		},
+	}
+
+	gui.NewChoiceWannabe(&nBodyChoice)
+	nBodyChoice.handleChoice(0)
+
+	bodyCountSlider := SliderWannabe{
+		y:           160,
+		canvasWidth: guiCanvasWidth,
+		parameter:   &bodyCount,
+		format:      "%.0f bodies",
+	}
+
+	gui.NewSliderWannabe(bodyCountSlider)
+
+	openingAngleSlider := SliderWannabe{
+		y:           250,
+		canvasWidth: guiCanvasWidth,
+		parameter:   &openingAngle,
+		format:      "theta %.2f",
+	}
+
+	gui.NewSliderWannabe(openingAngleSlider)
+
+	gravitySofteningSlider := SliderWannabe{
+		y:           340,
+		canvasWidth: guiCanvasWidth,
+		parameter:   &gravitySoftening,
+		format:      "softening %.2f m",
+	}
+
+	gui.NewSliderWannabe(gravitySofteningSlider)
+
+	gui.NewPage("Rigid", guiCanvasWidth)
+
+	rigidChoice := ChoiceWannabe{
+		y:           100,
+		canvasWidth: guiCanvasWidth,
+		options:     []string{"Off", "Stack", "Pile"},
+		onChoice: func(index int) {
+			room := pixel.R(guiCanvasWidth, win.Bounds().Min.Y, win.Bounds().Max.X,
+				win.Bounds().Max.Y)
+			switch index {
+			case 0:
+				rigidWorld = nil
+			case 1:
+				rigidWorld = sim.NewRigidStack(room, 6, 40)
+			case 2:
+				rigidWorld = sim.NewRigidPile(room, 24, 40)
+			}
+			if rigidWorld != nil {
+				rigidWorld.Iterations = &rigidIterations
+			}
+		},
+	}
+
+	gui.NewChoiceWannabe(&rigidChoice)
+	rigidChoice.handleChoice(0)
+
+	rigidFrictionSlider := SliderWannabe{
+		y:           160,
+		canvasWidth: guiCanvasWidth,
+		parameter:   &rigidFriction,
+		format:      "friction %.2f",
+	}
+
+	gui.NewSliderWannabe(rigidFrictionSlider)
+
+	rigidRestitutionSlider := SliderWannabe{
+		y:           250,
+		canvasWidth: guiCanvasWidth,
+		parameter:   &rigidRestitution,
+		format:      "restitution %.2f",
+	}
+
+	gui.NewSliderWannabe(rigidRestitutionSlider)
+
+	rigidIterationsSlider := SliderWannabe{
+		y:           340,
+		canvasWidth: guiCanvasWidth,
+		parameter:   &rigidIterations,
+		format:      "%.0f iterations",
+	}
+
+	gui.NewSliderWannabe(rigidIterationsSlider)
+
+	gui.NewPage("Springs", guiCanvasWidth)
+
+	bodyChoice := ChoiceWannabe{
+		y:           100,
+		canvasWidth: guiCanvasWidth,
+		options:     []string{"Off", "Rope", "Chain", "Cloth"},
+		onChoice: func(index int) {
+			switch index {
+			case 0:
+				body = nil
+			case 1:
+				body = sim.NewRope(pixel.V(620, 650), pixel.V(900, 650), 20, 0.2,
+					linkStiffness.Value, linkDamping.Value)
+			case 2:
+				body = sim.NewChain(pixel.V(480, 650), pixel.V(940, 650), 30, 1.2, 0.3,
+					linkStiffness.Value, linkDamping.Value)
+			case 3:
+				body = sim.NewCloth(pixel.V(530, 700), 20, 15, 18, 5, 2,
+					linkStiffness.Value, linkDamping.Value)
+			}
+			if body != nil {
+				body.Forces = particleSystem.Forces()
+			}
+			draggedParticle = -1
+		},
+	}
+
+	gui.NewChoiceWannabe(&bodyChoice)
+	bodyChoice.handleChoice(0)
+
+	linkStiffnessSlider := SliderWannabe{
+		y:           160,
+		canvasWidth: guiCanvasWidth,
+		parameter:   &linkStiffness,
+		format:      "stiffness %.0f N/m",
+	}
+
+	gui.NewSliderWannabe(linkStiffnessSlider)
+
+	linkDampingSlider := SliderWannabe{
+		y:           250,
+		canvasWidth: guiCanvasWidth,
+		parameter:   &linkDamping,
+		format:      "damping %.1f Ns/m",
+	}
+
+	gui.NewSliderWannabe(linkDampingSlider)
+
+	gui.NewPage("PBD", guiCanvasWidth)
+
+	positionBasedChoice := ChoiceWannabe{
+		y:           100,
+		canvasWidth: guiCanvasWidth,
+		options:     []string{"Off", "Rope", "Ragdoll", "Soft"},
+		onChoice: func(index int) {
+			switch index {
+			case 0:
+				positionBasedBody = nil
+			case 1:
+				positionBasedBody = sim.NewPBDRope(pixel.V(620, 650), pixel.V(900, 650), 25, 0.3,
+					distanceCompliance.Value, bendingCompliance.Value)
+			case 2:
+				positionBasedBody = sim.NewRagdoll(pixel.V(700, 600), 150, 5,
+					distanceCompliance.Value, bendingCompliance.Value)
+			case 3:
+				positionBasedBody = sim.NewSoftBody(pixel.V(700, 600), 80, 24, 1,
+					distanceCompliance.Value, bendingCompliance.Value)
+			}
+			if positionBasedBody != nil {
+				positionBasedBody.Forces = particleSystem.Forces()
+				positionBasedBody.Iterations = &solverIterations
+			}
+			draggedPin = nil
+		},
+	}
+
+	gui.NewChoiceWannabe(&positionBasedChoice)
+	positionBasedChoice.handleChoice(0)
+
+	solverIterationsSlider := SliderWannabe{
+		y:           160,
+		canvasWidth: guiCanvasWidth,
+		parameter:   &solverIterations,
+		format:      "%.0f iterations",
+	}
+
+	gui.NewSliderWannabe(solverIterationsSlider)
+
+	distanceComplianceSlider := SliderWannabe{
+		y:           250,
+		canvasWidth: guiCanvasWidth,
+		parameter:   &distanceCompliance,
+		format:      "stretch %.4f m/N",
+	}
+
+	gui.NewSliderWannabe(distanceComplianceSlider)
+
+	bendingComplianceSlider := SliderWannabe{
+		y:           340,
+		canvasWidth: guiCanvasWidth,
+		parameter:   &bendingCompliance,
+		format:      "bend %.2f rad/Nm",
+	}
+
+	gui.NewSliderWannabe(bendingComplianceSlider)
 
 	cam := pixel.IM.Scaled(camPos, 1.0).Moved(win.Bounds().Center().Sub(camPos))
 
@@ -266,75 +1103,196 @@ func run() {
 	gui.canvas.Clear(colornames.White)
 
 	imd := imdraw.New(nil)
-	circle.draw(imd, pixel.V(0, 0).Sub(win.Bounds().Center()).Add(circle.position))
+
+	windImd := imdraw.New(nil)
+	attractorImd := imdraw.New(nil)
 
 	for !win.Closed() {
 		win.Update()
 		gui.Draw()
 
-		if win.Pressed(pixelgl.MouseButtonLeft) && circle.isPositionInside(win.MousePosition()) {
-			circle.position = win.MousePosition()
-			imd.Clear()
-			circle.draw(imd, pixel.V(0, 0).Sub(win.Bounds().Center()).Add(circle.position))
+		// particles of mass-spring system are dragged by left mouse button
+		if !win.Pressed(pixelgl.MouseButtonLeft) {
+			draggedParticle = -1
+			draggedCollider = nil
+		} else if body != nil && draggedParticle < 0 && win.MousePosition().X > guiCanvasWidth {
+			draggedParticle = body.ParticleAt(win.MousePosition(), 8)
+		}
+
+		// particles of position-based body are dragged by a pin following the mouse
+		if !win.Pressed(pixelgl.MouseButtonLeft) && draggedPin != nil {
+			if draggedPinTemporary {
+				positionBasedBody.Unpin(draggedPin)
+			}
+			draggedPin = nil
+		} else if win.Pressed(pixelgl.MouseButtonLeft) && positionBasedBody != nil &&
+			draggedPin == nil && draggedParticle < 0 && win.MousePosition().X > guiCanvasWidth {
+			if index := positionBasedBody.ParticleAt(win.MousePosition(), 8); index >= 0 {
+				draggedPin = positionBasedBody.PinOf(index)
+				draggedPinTemporary = draggedPin == nil
+				if draggedPinTemporary {
+					draggedPin = positionBasedBody.Pin(index)
+				}
+			}
+		}
+
+		if draggedPin != nil {
+			draggedPin.Position = win.MousePosition()
+		} else if draggedParticle >= 0 {
+			body.Move(draggedParticle, win.MousePosition(), clock.Dt())
+		} else if draggedCollider != nil {
+			scene.Move(draggedCollider, win.MousePosition().Sub(lastMouse))
+		} else if win.Pressed(pixelgl.MouseButtonLeft) && win.MousePosition().X > guiCanvasWidth &&
+			scene.ColliderAt(win.MousePosition(), 8) != nil {
+			draggedCollider = scene.ColliderAt(win.MousePosition(), 8)
+			selectedCollider = draggedCollider
+			obstacleRestitution.Value = selectedCollider.Surface().Restitution
+			obstacleFriction.Value = selectedCollider.Surface().Friction
+		} else if win.Pressed(pixelgl.MouseButtonLeft) {
+			if attractor := attractors.AttractorAt(win.MousePosition()); attractor != nil {
+				attractor.Position = win.MousePosition()
+				if attractor != attractors.selected {
+					attractors.Select(attractor)
+					falloffChoice.handleChoice(int(attractor.Falloff))
+				}
+			}
+		}
+
+		// right click outside of gui places a new attractor or removes an existing one
+		if win.JustPressed(pixelgl.MouseButtonRight) && win.MousePosition().X > guiCanvasWidth {
+			if attractor := attractors.AttractorAt(win.MousePosition()); attractor != nil {
+				attractors.Remove(attractor)
+				particleSystem.RemoveForce(attractor)
+			} else {
+				particleSystem.AddForce(attractors.Add(win.MousePosition()))
+			}
+		}
+
+		attractors.Apply()
+
+		if body != nil {
+			body.SetStiffness(linkStiffness.Value, linkDamping.Value)
+		}
+		if positionBasedBody != nil {
+			positionBasedBody.SetCompliance(distanceCompliance.Value, bendingCompliance.Value)
+		}
+		lastMouse = win.MousePosition()
+
+		material := sim.Material{Restitution: obstacleRestitution.Value, Friction: obstacleFriction.Value}
+		if selectedCollider != nil {
+			*selectedCollider.Surface() = material
+		} else {
+			for _, collider := range scene.Colliders() {
+				*collider.Surface() = material
+			}
+		}
+
+		if rigidWorld != nil {
+			// colliders are static bodies of the world
+			rigidWorld.SetObstacles(scene.Colliders())
+			rigidWorld.SetMaterial(rigidFriction.Value, rigidRestitution.Value)
 		}
 
 		if !gui.GetState().paused && !gui.GetState().stopped {
-			dt := time.Since(last).Seconds()
+			steps := clock.Advance(time.Since(last).Seconds())
 			last = time.Now()
-			timeElapsed += dt
+			dt := clock.Dt()
+
+			// physics advances by fixed steps, so the results do not depend on the frame rate
+			for step := 0; step < steps; step++ {
+				wind.Advance(dt)
+
+				particleSystem.Update(
+					dt,
+					positionIntegratorSwitch.positionIntegrator,
+					scene,
+					&collisions,
+					&fluid,
+					&boundary,
+				)
+
+				if rigidWorld != nil {
+					rigidWorld.Update(dt)
+					rigidWorld.CollideParticles(particleSystem.Particles(), dt)
+				}
+				if nBody != nil {
+					nBody.Update(dt, positionIntegratorSwitch.positionIntegrator)
+				}
+				if body != nil {
+					body.Update(dt, positionIntegratorSwitch.positionIntegrator, scene)
+				}
+				if positionBasedBody != nil {
+					positionBasedBody.Update(dt, scene, win.Bounds())
+				}
 
-			batch.Clear()
+				particleSystem.Emit(dt)
+				// particles die at the step they reach their lifespan, not at the end of the frame
+				particleSystem.KillOldParticles(
+					win.Bounds().Min.X,
+					win.Bounds().Max.X,
+					win.Bounds().Min.Y,
+					boundary.Open(),
+				)
+			}
 
-			updateParticles(
-				particleSystem.particles,
-				batch,
-				dt,
-				cam,
-				positionIntegratorSwitch.positionIntegrator,
-				circle,
-			)
+			// rendering is between the last two physics states
+			alpha := clock.Alpha()
+
+			batch.Clear()
+			drawParticles(particleSystem.Particles(), particleSprite, batch, cam, alpha, &fluid)
+			if nBody != nil {
+				drawNBody(nBody, particleSprite, batch, cam, alpha)
+			}
 
 			win.Clear(colornames.Whitesmoke)
 
 			batch.Draw(win)
 
+			imd.Clear()
+			drawScene(imd, scene, pixel.V(0, 0).Sub(win.Bounds().Center()))
+			if body != nil {
+				drawMassSpring(imd, body, pixel.V(0, 0).Sub(win.Bounds().Center()), alpha)
+			}
+			if rigidWorld != nil {
+				drawRigidWorld(imd, rigidWorld, pixel.V(0, 0).Sub(win.Bounds().Center()), alpha)
+			}
+			if positionBasedBody != nil {
+				drawPositionBasedBody(imd, positionBasedBody, pixel.V(0, 0).Sub(win.Bounds().Center()),
+					alpha)
+			}
 			imd.Draw(win)
 
+			attractorImd.Clear()
+			attractors.draw(attractorImd, pixel.V(0, 0).Sub(win.Bounds().Center()))
+			attractorImd.Draw(win)
+
+			if windOverlay {
+				windImd.Clear()
+				drawWind(windImd, &wind, win.Bounds(), pixel.V(0, 0).Sub(win.Bounds().Center()))
+				windImd.Draw(win)
+			}
+
 			gui.canvas.Draw(
 				win,
 				pixel.IM.Moved(pixel.V((win.Bounds().W()/-2.0)+(gui.canvas.Bounds().W()/2.0), 0.0)),
 			)
 			gui.batch.Draw(win)
+			gui.DrawLabels(win)
 			gui.DrawText(win)
-
-			timeForOneParticle := 1.0 / float64(particleSystem.emitRate.value)
-
-			for timeElapsed > timeForOneParticle {
-				pos := particleSystem.position
-				angle := (rand.Float64() - 0.5) * (particleSystem.angle.value * (math.Pi / 180))
-				speed := pixel.V(0, initialVelocity.value).Rotated(angle)
-				nextPost := pos.Add(speed.Scaled(PixelsPerMeter).Scaled(prevDt)).Add(
-					Gravity.Scaled(PixelsPerMeter).Scaled(prevDt * prevDt * 0.5))
-
-				particle := Particle{
-					position:     pos,
-					nextPosition: nextPost,
-					speed:        speed,
-					prevDt:       prevDt,
-					sprite:       *particleSprite,
-					lifespan:     particleLife.value,
-					alive:        0.0,
-				}
-				particleSystem.particles = append(particleSystem.particles, particle)
-				timeElapsed = timeElapsed - timeForOneParticle
-			}
 		} else if gui.GetState().paused && !gui.GetState().stopped {
 			last = time.Now()
 			gui.batch.Draw(gui.win)
+			gui.DrawLabels(gui.win)
 		} else {
 			last = time.Now()
-			timeElapsed = 0
-			particleSystem.particles = particleSystem.particles[:0]
+			clock.Reset()
+			particleSystem.Reset()
+			wind.Reset()
+			// bodies are built again from their initial state, galaxies from the seed
+			rigidChoice.handleChoice(rigidChoice.selected)
+			nBodyChoice.handleChoice(nBodyChoice.selected)
+			bodyChoice.handleChoice(bodyChoice.selected)
+			positionBasedChoice.handleChoice(positionBasedChoice.selected)
 			batch.Clear()
 			win.Clear(colornames.Whitesmoke)
 			gui.canvas.Draw(
@@ -342,18 +1300,23 @@ func run() {
 				pixel.IM.Moved(pixel.V((win.Bounds().W()/-2.0)+(gui.canvas.Bounds().W()/2.0), 0.0)),
 			)
 			gui.batch.Draw(gui.win)
+			gui.DrawLabels(gui.win)
 		}
-		particleSystem.KillOldParticles(
-			win.Bounds().Min.X,
-			win.Bounds().Max.X,
-			win.Bounds().Min.Y,
-		)
-
 		frames++
 		select {
 		case <-second:
-			win.SetTitle(fmt.Sprintf("%s | FPS: %d | particles %d", cfg.Title, frames,
-				len(particleSystem.particles)))
+			title := fmt.Sprintf("%s | FPS: %d | particles %d", cfg.Title, frames,
+				particleSystem.Particles().Len())
+			if counter, ok := positionIntegratorSwitch.positionIntegrator.(sim.StepCounter); ok {
+				accepted, rejected := counter.StepCount()
+				title += fmt.Sprintf(" | steps accepted %d rejected %d", accepted, rejected)
+			}
+			if nBody != nil {
+				energy, momentum := nBody.Drift()
+				title += fmt.Sprintf(" | %s energy drift %+.2e angular momentum drift %+.2e",
+					positionIntegratorSwitch.positionIntegrator.Name(), energy, momentum)
+			}
+			win.SetTitle(title)
 			frames = 0
 		default:
 		}
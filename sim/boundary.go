@@ -0,0 +1,104 @@
+package sim
+
+import "github.com/faiface/pixel"
+
+// BoundaryMode is enum for choosing what happens to particles reaching the edges of the window
+type BoundaryMode int
+
+const (
+	// OpenBoundary lets particles leave the window, they are removed on the left, right and bottom
+	OpenBoundary BoundaryMode = iota
+	// WallBoundary turns the left, right and bottom edge to solid walls
+	WallBoundary BoundaryMode = iota
+	// BoxBoundary turns all four edges to solid walls
+	BoxBoundary BoundaryMode = iota
+	// PeriodicBoundary moves particles leaving the window over one edge to the opposite edge
+	PeriodicBoundary BoundaryMode = iota
+)
+
+// Boundary represents edges of the area particles move in
+type Boundary struct {
+	Mode        BoundaryMode
+	Bounds      pixel.Rect // in pixels
+	Restitution *Parameter // coefficient of restitution of the walls
+}
+
+// Open reports whether particles leaving the bounds are removed
+func (boundary *Boundary) Open() bool {
+	return boundary.Mode == OpenBoundary
+}
+
+// Resolve bounces particles off the walls or wraps them around the bounds. Wrapped particles keep
+// their history of positions, so Verlet integrator continues smoothly. Forces between particles
+// do not act over the edges
+func (boundary *Boundary) Resolve(particles *Particles, dt float64) {
+	switch boundary.Mode {
+	case WallBoundary, BoxBoundary:
+		for i := range particles.position {
+			if boundary.bounce(particles, i) {
+				particles.resetHistory(i, dt)
+			}
+		}
+	case PeriodicBoundary:
+		for i := range particles.position {
+			boundary.wrap(particles, i)
+		}
+	}
+}
+
+// bounce moves the particle with index i inside the walls, reverses its normal speed scaled by
+// restitution and reports whether it hit any wall
+func (boundary *Boundary) bounce(particles *Particles, i int) bool {
+	restitution := boundary.Restitution.Value
+	radius := particles.radius[i] * PixelsPerMeter
+	min := boundary.Bounds.Min.Add(pixel.V(radius, radius))
+	max := boundary.Bounds.Max.Sub(pixel.V(radius, radius))
+	position, speed := &particles.position[i], &particles.speed[i]
+
+	hit := false
+	if position.X < min.X {
+		position.X, hit = min.X, true
+		if speed.X < 0 {
+			speed.X = -restitution * speed.X
+		}
+	}
+	if position.X > max.X {
+		position.X, hit = max.X, true
+		if speed.X > 0 {
+			speed.X = -restitution * speed.X
+		}
+	}
+	if position.Y < min.Y {
+		position.Y, hit = min.Y, true
+		if speed.Y < 0 {
+			speed.Y = -restitution * speed.Y
+		}
+	}
+	if boundary.Mode == BoxBoundary && position.Y > max.Y {
+		position.Y, hit = max.Y, true
+		if speed.Y > 0 {
+			speed.Y = -restitution * speed.Y
+		}
+	}
+	return hit
+}
+
+// wrap moves the particle with index i which left the bounds over one edge by the size of the
+// bounds
+func (boundary *Boundary) wrap(particles *Particles, i int) {
+	position := particles.position[i]
+	shift := pixel.ZV
+	if position.X < boundary.Bounds.Min.X {
+		shift.X = boundary.Bounds.W()
+	} else if position.X >= boundary.Bounds.Max.X {
+		shift.X = -boundary.Bounds.W()
+	}
+	if position.Y < boundary.Bounds.Min.Y {
+		shift.Y = boundary.Bounds.H()
+	} else if position.Y >= boundary.Bounds.Max.Y {
+		shift.Y = -boundary.Bounds.H()
+	}
+	particles.position[i] = position.Add(shift)
+	particles.lastPosition[i] = particles.lastPosition[i].Add(shift)
+	particles.nextPosition[i] = particles.nextPosition[i].Add(shift)
+}
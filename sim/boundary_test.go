@@ -0,0 +1,111 @@
+package sim
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/faiface/pixel"
+)
+
+// TestWbox tests gas of particles in a box stays inside and keeps its energy with elastic walls
+func TestWbox(t *testing.T) {
+	boundary := Boundary{
+		Mode:        BoxBoundary,
+		Bounds:      pixel.R(0, 0, 200, 100),
+		Restitution: &Parameter{Value: 1},
+	}
+
+	for _, integrator := range Integrators() {
+		random := rand.New(rand.NewSource(1))
+		particles := NewParticles(0, &ForceField{})
+		for i := 0; i < 100; i++ {
+			p := Particle{
+				Position: pixel.V(10+random.Float64()*180, 10+random.Float64()*80),
+				Speed:    pixel.V(random.NormFloat64(), random.NormFloat64()).Scaled(5),
+				Mass:     1,
+				Radius:   ParticleRadius,
+				Forces:   &ForceField{},
+			}
+			p.resetHistory(0.01)
+			particles.Add(p)
+		}
+
+		eEnergy := kineticEnergy(particles.slice())
+		for step := 0; step < 500; step++ {
+			integrate(particles, integrator, 0.01)
+			boundary.Resolve(particles, 0.01)
+		}
+
+		for i := 0; i < particles.Len(); i++ {
+			if !boundary.Bounds.Contains(particles.Position(i)) {
+				t.Fatalf("%s box: Expected particle inside %v got %v", integrator.Name(),
+					boundary.Bounds, particles.Position(i))
+			}
+		}
+		if energy := kineticEnergy(particles.slice()); math.Abs(energy-eEnergy) > 1e-6*eEnergy {
+			t.Errorf("%s box: Expected kinetic energy of %f got %f", integrator.Name(), eEnergy,
+				energy)
+		}
+	}
+}
+
+// TestWwalls tests a ball dropped on the floor between walls bounces lower with inelastic walls
+func TestWwalls(t *testing.T) {
+	boundary := Boundary{
+		Mode:        WallBoundary,
+		Bounds:      pixel.R(0, 0, 100, 100),
+		Restitution: &Parameter{Value: 0.5},
+	}
+
+	particles := NewParticles(0, nil)
+	particles.Add(Particle{Position: pixel.V(50, 90), Speed: pixel.V(3, 0), Mass: 1, Radius: 0.1})
+	const dt = 0.001
+	maxHeight := 0.0
+	for step := 0; step < 2000; step++ {
+		integrate(particles, ExplicitEulerIntegrator{}, dt)
+		boundary.Resolve(particles, dt)
+		if p := particles.Position(0); p.X < 10 || p.X > 90 || p.Y < 10 {
+			t.Fatalf("Walls: Expected ball inside the walls got %v", p)
+		}
+		// height of the bounce after the first hit of the floor
+		if step > 500 {
+			maxHeight = math.Max(maxHeight, particles.Position(0).Y)
+		}
+	}
+	if maxHeight > 40 {
+		t.Errorf("Walls: Expected bounce lower than 40 px got %f", maxHeight)
+	}
+}
+
+// TestWwrap tests particle leaving the bounds over an edge continues from the opposite edge
+func TestWwrap(t *testing.T) {
+	boundary := Boundary{
+		Mode:   PeriodicBoundary,
+		Bounds: pixel.R(0, 0, 100, 100),
+	}
+
+	for _, integrator := range Integrators() {
+		particles := NewParticles(0, &ForceField{})
+		p := Particle{
+			Position: pixel.V(50, 50),
+			Speed:    pixel.V(3, -2),
+			Mass:     1,
+			Radius:   ParticleRadius,
+			Forces:   &ForceField{},
+		}
+		p.resetHistory(0.01)
+		particles.Add(p)
+
+		for step := 0; step < 100; step++ {
+			integrate(particles, integrator, 0.01)
+			boundary.Resolve(particles, 0.01)
+		}
+
+		// 300 px to the right and 200 px down is the same position after wrapping
+		if diff := particles.Position(0).To(pixel.V(50, 50)).Len(); diff > 1e-6 {
+			t.Errorf("%s wrap: Expected position of %v got %v", integrator.Name(), pixel.V(50, 50),
+				particles.Position(0))
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package sim
+
+import "math"
+
+// FixedStep represents loop of physics steps of constant length. Real time of frames is
+// accumulated and consumed by whole steps, so results do not depend on the frame rate. Rendering
+// interpolates between the last two physics states by Alpha
+type FixedStep struct {
+	Rate        *Parameter // physics steps per second
+	MaxSubsteps *Parameter // most steps taken in one frame, the rest of the time is dropped
+	MaxFrame    float64    // in s, longer frames, e.g. window drags or pauses, are clamped
+	accumulator float64    // in s, real time not consumed by the steps yet
+}
+
+// Dt returns length of the physics step in s
+func (clock *FixedStep) Dt() float64 {
+	return 1 / clock.Rate.Value
+}
+
+// Advance adds real time of a frame in s and returns number of physics steps to take
+func (clock *FixedStep) Advance(frame float64) int {
+	if clock.MaxFrame > 0 {
+		frame = math.Min(frame, clock.MaxFrame)
+	}
+	clock.accumulator += math.Max(frame, 0)
+
+	dt := clock.Dt()
+	steps := int(clock.accumulator / dt)
+	if max := int(clock.MaxSubsteps.Value); steps > max {
+		// the simulation can not keep up, it slows down instead of taking ever more steps
+		clock.accumulator = 0
+		return max
+	}
+	clock.accumulator -= float64(steps) * dt
+	return steps
+}
+
+// Alpha returns fraction of the step the real time is ahead of the last physics state
+func (clock *FixedStep) Alpha() float64 {
+	return math.Min(clock.accumulator/clock.Dt(), 1)
+}
+
+// Reset drops the accumulated time
+func (clock *FixedStep) Reset() {
+	clock.accumulator = 0
+}
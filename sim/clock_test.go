@@ -0,0 +1,205 @@
+package sim
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/faiface/pixel"
+)
+
+// TestTaccumulator tests all real time of frames is consumed by fixed steps
+func TestTaccumulator(t *testing.T) {
+	clock := FixedStep{
+		Rate:        &Parameter{Value: 240},
+		MaxSubsteps: &Parameter{Value: 100},
+		MaxFrame:    0.25,
+	}
+
+	random := rand.New(rand.NewSource(1))
+	total, steps := 0.0, 0
+	for frame := 0; frame < 1000; frame++ {
+		length := random.Float64() / 30
+		total += length
+		steps += clock.Advance(length)
+	}
+
+	if consumed := float64(steps)*clock.Dt() + clock.accumulator; math.Abs(consumed-total) > 1e-9 {
+		t.Errorf("Fixed step: Expected consumed time of %f s got %f s", total, consumed)
+	}
+	if alpha := clock.Alpha(); alpha < 0 || alpha >= 1 {
+		t.Errorf("Fixed step: Expected alpha in [0, 1) got %f", alpha)
+	}
+}
+
+// TestTclamp tests long frames are clamped and steps per frame are limited
+func TestTclamp(t *testing.T) {
+	clock := FixedStep{
+		Rate:        &Parameter{Value: 100},
+		MaxSubsteps: &Parameter{Value: 100},
+		MaxFrame:    0.25,
+	}
+	if steps := clock.Advance(10); steps != 25 {
+		t.Errorf("Fixed step: Expected 25 steps of clamped frame got %d", steps)
+	}
+
+	clock.MaxSubsteps.Value = 4
+	if steps := clock.Advance(0.1); steps != 4 {
+		t.Errorf("Fixed step: Expected 4 steps got %d", steps)
+	}
+	if steps := clock.Advance(0); steps != 0 {
+		t.Errorf("Fixed step: Expected time over the limit of steps to be dropped got %d steps",
+			steps)
+	}
+}
+
+// TestTreproducible tests particles advanced by fixed steps end in the same state regardless of
+// the frame rate. Particles are killed at the step they reach their lifespan, so colliding
+// particles which outlive others do not depend on the frame rate either
+func TestTreproducible(t *testing.T) {
+	stokes := StokesDrag
+	simulate := func(frame func(i int) float64) []Particle {
+		clock := FixedStep{
+			Rate:        &Parameter{Value: 240},
+			MaxSubsteps: &Parameter{Value: 8},
+			MaxFrame:    0.25,
+		}
+		particleSystem := &ParticleSystem{}
+		particleSystem.AddForce(UniformGravity{Acceleration: Gravity})
+		particleSystem.AddForce(Drag{Mode: &stokes, Viscosity: &Parameter{Value: 0.5}})
+
+		random := rand.New(rand.NewSource(1))
+		particles := particleSystem.Particles()
+		for i := 0; i < 40; i++ {
+			particles.Add(Particle{
+				Position: pixel.V(10+random.Float64()*80, 10+random.Float64()*80),
+				Speed:    pixel.V(random.NormFloat64(), random.NormFloat64()),
+				Mass:     1,
+				Radius:   0.05,
+				Lifespan: 0.5 + 0.05*float64(i),
+			})
+		}
+
+		// particles in a small box collide with each other all the time
+		collisions := &ParticleCollisions{Enabled: true, Restitution: &Parameter{Value: 0.9}}
+		boundary := &Boundary{Mode: BoxBoundary, Bounds: pixel.R(0, 0, 100, 100),
+			Restitution: &Parameter{Value: 0.9}}
+		for i, steps := 0, 0; steps < 480; i++ {
+			for n := clock.Advance(frame(i)); n > 0 && steps < 480; n-- {
+				particleSystem.Update(clock.Dt(), RK4Integrator{}, NewScene(), collisions,
+					&Fluid{}, boundary)
+				particleSystem.KillOldParticles(0, 0, 0, false)
+				steps++
+			}
+		}
+		return particles.slice()
+	}
+
+	random := rand.New(rand.NewSource(1))
+	steady := simulate(func(int) float64 { return 1.0 / 60 })
+	jittery := simulate(func(int) float64 { return random.Float64() / 20 })
+
+	if len(steady) == 0 || len(steady) == 40 || len(jittery) != len(steady) {
+		t.Fatalf("Fixed step: Expected %d surviving particles got %d", len(steady), len(jittery))
+	}
+	for i := range steady {
+		if steady[i].Position != jittery[i].Position || steady[i].Speed != jittery[i].Speed {
+			t.Errorf("Fixed step particle %d: Expected position of %v got %v", i,
+				steady[i].Position, jittery[i].Position)
+		}
+	}
+}
+
+// TestTseed tests emitters with the same seed advanced by fixed steps reproduce the run bit for
+// bit regardless of the frame rate, also after Reset of the system and the wind, and that another
+// seed gives another run
+func TestTseed(t *testing.T) {
+	newSystem := func(seed float64) (*ParticleSystem, *Wind) {
+		particleSystem := &ParticleSystem{
+			Position:        pixel.V(400, 100),
+			EmitRate:        &Parameter{Value: 500},
+			Angle:           &Parameter{Value: 60},
+			Lifespan:        &Parameter{Value: 0.5},
+			Speed:           &Parameter{Value: 9.5},
+			Mass:            &Parameter{Value: 0.05},
+			Radius:          &Parameter{Value: ParticleRadius},
+			DragCoefficient: &Parameter{Value: 0.47},
+			Seed:            &Parameter{Value: seed},
+		}
+		wind := &Wind{
+			Enabled:   true,
+			Angle:     &Parameter{Value: 0},
+			Speed:     &Parameter{Value: 2},
+			Strength:  &Parameter{Value: 3},
+			Scale:     &Parameter{Value: 1},
+			Evolution: &Parameter{Value: 0.5},
+			Response:  0.5,
+		}
+		particleSystem.AddForce(UniformGravity{Acceleration: Gravity})
+		particleSystem.AddForce(wind)
+		return particleSystem, wind
+	}
+	simulate := func(particleSystem *ParticleSystem, wind *Wind,
+		frame func(i int) float64) []Particle {
+		clock := FixedStep{Rate: &Parameter{Value: 240}, MaxSubsteps: &Parameter{Value: 8},
+			MaxFrame: 0.25}
+		scene := NewPegScene(pixel.R(0, 0, 800, 600), CircleShape)
+		collisions := &ParticleCollisions{Enabled: true, Restitution: &Parameter{Value: 0.9}}
+		for i, steps := 0, 0; steps < 480; i++ {
+			for n := clock.Advance(frame(i)); n > 0 && steps < 480; n-- {
+				wind.Advance(clock.Dt())
+				particleSystem.Update(clock.Dt(), RK4Integrator{}, scene, collisions, &Fluid{},
+					&Boundary{})
+				particleSystem.Emit(clock.Dt())
+				particleSystem.KillOldParticles(0, 800, 0, true)
+				steps++
+			}
+		}
+
+		// forces of different systems differ by address only
+		result := particleSystem.Particles().slice()
+		for i := range result {
+			result[i].Forces = nil
+		}
+		return result
+	}
+	equal := func(a []Particle, b []Particle) bool {
+		if len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if a[i] != b[i] {
+				return false
+			}
+		}
+		return true
+	}
+
+	random := rand.New(rand.NewSource(1))
+	steady := func(int) float64 { return 1.0 / 60 }
+	jittery := func(int) float64 { return random.Float64() / 20 }
+
+	replayed, replayedWind := newSystem(7)
+	expected := simulate(replayed, replayedWind, steady)
+	if len(expected) == 0 {
+		t.Fatalf("Seed: Expected particles got none")
+	}
+
+	system, wind := newSystem(7)
+	if result := simulate(system, wind, jittery); !equal(result, expected) {
+		t.Errorf("Seed %d jittery frames: Expected the same run got %d particles instead of %d", 7,
+			len(result), len(expected))
+	}
+
+	replayed.Reset()
+	replayedWind.Reset()
+	if result := simulate(replayed, replayedWind, steady); !equal(result, expected) {
+		t.Errorf("Seed %d after reset: Expected the same run got %d particles instead of %d", 7,
+			len(result), len(expected))
+	}
+
+	system, wind = newSystem(8)
+	if equal(simulate(system, wind, steady), expected) {
+		t.Errorf("Seed %d: Expected another run than with seed %d", 8, 7)
+	}
+}
@@ -0,0 +1,457 @@
+package sim
+
+import (
+	"math"
+
+	"github.com/faiface/pixel"
+)
+
+// Collider represents static obstacle which particles collide with. Positions are in pixels
+type Collider interface {
+	// Contains reports whether position is inside of the collider
+	Contains(position pixel.Vec) bool
+	// ClosestPoint returns point of the boundary of the collider closest to position
+	ClosestPoint(position pixel.Vec) pixel.Vec
+	// Normal returns outward unit normal of the boundary at the point closest to position
+	Normal(position pixel.Vec) pixel.Vec
+	// Move moves the collider by delta
+	Move(delta pixel.Vec)
+	// Bounds returns axis-aligned bounding box of the collider
+	Bounds() pixel.Rect
+	// Surface returns material of the collider
+	Surface() *Material
+}
+
+// Material represents surface of a collider. It is embedded in colliders
+type Material struct {
+	Restitution float64 // coefficient of restitution of the normal speed
+	Friction    float64 // coefficient of Coulomb friction
+}
+
+// Surface returns the material
+func (material *Material) Surface() *Material {
+	return material
+}
+
+// Contains reports whether position is inside of the circle
+func (circle *Circle) Contains(position pixel.Vec) bool {
+	return circle.Position.To(position).Len() <= circle.Radius
+}
+
+// ClosestPoint returns point of the circle closest to position
+func (circle *Circle) ClosestPoint(position pixel.Vec) pixel.Vec {
+	return circle.Position.Add(circle.Normal(position).Scaled(circle.Radius))
+}
+
+// Normal returns outward normal of the circle in direction of position
+func (circle *Circle) Normal(position pixel.Vec) pixel.Vec {
+	return position.Sub(circle.Position).Unit()
+}
+
+// Move moves the circle by delta
+func (circle *Circle) Move(delta pixel.Vec) {
+	circle.Position = circle.Position.Add(delta)
+}
+
+// Bounds returns bounding box of the circle
+func (circle *Circle) Bounds() pixel.Rect {
+	return pixel.R(circle.Position.X-circle.Radius, circle.Position.Y-circle.Radius,
+		circle.Position.X+circle.Radius, circle.Position.Y+circle.Radius)
+}
+
+// pointsBounds returns bounding box of points
+func pointsBounds(points ...pixel.Vec) pixel.Rect {
+	bounds := pixel.R(points[0].X, points[0].Y, points[0].X, points[0].Y)
+	for _, point := range points[1:] {
+		bounds.Min = pixel.V(math.Min(bounds.Min.X, point.X), math.Min(bounds.Min.Y, point.Y))
+		bounds.Max = pixel.V(math.Max(bounds.Max.X, point.X), math.Max(bounds.Max.Y, point.Y))
+	}
+	return bounds
+}
+
+// ConvexPolygon represents convex polygon with counter-clockwise vertices
+type ConvexPolygon struct {
+	Material
+	Vertices []pixel.Vec
+}
+
+// nearest returns point of the boundary of the polygon closest to position and outward normal
+// at this point
+func (polygon *ConvexPolygon) nearest(position pixel.Vec) (pixel.Vec, pixel.Vec) {
+	// inside of the polygon the nearest face has the largest (negative) separation
+	inside, face, maxSeparation := true, 0, math.Inf(-1)
+	for i := range polygon.Vertices {
+		separation := polygonNormal(polygon.Vertices, i).Dot(position.Sub(polygon.Vertices[i]))
+		if separation > 0 {
+			inside = false
+		}
+		if separation > maxSeparation {
+			face, maxSeparation = i, separation
+		}
+	}
+	if inside {
+		normal := polygonNormal(polygon.Vertices, face)
+		return position.Sub(normal.Scaled(maxSeparation)), normal
+	}
+
+	closest, minDistance := pixel.ZV, math.Inf(1)
+	for i := range polygon.Vertices {
+		point := closestPointOnSegment(position, polygon.Vertices[i],
+			polygon.Vertices[(i+1)%len(polygon.Vertices)])
+		if distance := point.To(position).Len(); distance < minDistance {
+			closest, minDistance = point, distance
+		}
+	}
+	return closest, position.Sub(closest).Unit()
+}
+
+// Contains reports whether position is inside of the polygon
+func (polygon *ConvexPolygon) Contains(position pixel.Vec) bool {
+	for i := range polygon.Vertices {
+		if polygonNormal(polygon.Vertices, i).Dot(position.Sub(polygon.Vertices[i])) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// ClosestPoint returns point of the boundary of the polygon closest to position
+func (polygon *ConvexPolygon) ClosestPoint(position pixel.Vec) pixel.Vec {
+	point, _ := polygon.nearest(position)
+	return point
+}
+
+// Normal returns outward normal of the polygon at the point closest to position
+func (polygon *ConvexPolygon) Normal(position pixel.Vec) pixel.Vec {
+	_, normal := polygon.nearest(position)
+	return normal
+}
+
+// Move moves the polygon by delta
+func (polygon *ConvexPolygon) Move(delta pixel.Vec) {
+	for i := range polygon.Vertices {
+		polygon.Vertices[i] = polygon.Vertices[i].Add(delta)
+	}
+}
+
+// Bounds returns bounding box of the polygon
+func (polygon *ConvexPolygon) Bounds() pixel.Rect {
+	return pointsBounds(polygon.Vertices...)
+}
+
+// Capsule represents segment a-b inflated by radius
+type Capsule struct {
+	Material
+	A, B   pixel.Vec
+	Radius float64
+}
+
+// nearest returns point of the boundary of the capsule closest to position and outward normal
+// at this point
+func (capsule *Capsule) nearest(position pixel.Vec) (pixel.Vec, pixel.Vec) {
+	axis := closestPointOnSegment(position, capsule.A, capsule.B)
+	normal := position.Sub(axis)
+	if normal.Len() == 0 {
+		// on the axis the capsule is left sideways
+		normal = capsule.B.Sub(capsule.A).Normal()
+	}
+	normal = normal.Unit()
+	return axis.Add(normal.Scaled(capsule.Radius)), normal
+}
+
+// Contains reports whether position is inside of the capsule
+func (capsule *Capsule) Contains(position pixel.Vec) bool {
+	return position.To(closestPointOnSegment(position, capsule.A, capsule.B)).Len() <=
+		capsule.Radius
+}
+
+// ClosestPoint returns point of the boundary of the capsule closest to position
+func (capsule *Capsule) ClosestPoint(position pixel.Vec) pixel.Vec {
+	point, _ := capsule.nearest(position)
+	return point
+}
+
+// Normal returns outward normal of the capsule at the point closest to position
+func (capsule *Capsule) Normal(position pixel.Vec) pixel.Vec {
+	_, normal := capsule.nearest(position)
+	return normal
+}
+
+// Move moves the capsule by delta
+func (capsule *Capsule) Move(delta pixel.Vec) {
+	capsule.A = capsule.A.Add(delta)
+	capsule.B = capsule.B.Add(delta)
+}
+
+// Bounds returns bounding box of the capsule
+func (capsule *Capsule) Bounds() pixel.Rect {
+	bounds := pointsBounds(capsule.A, capsule.B)
+	return pixel.R(bounds.Min.X-capsule.Radius, bounds.Min.Y-capsule.Radius,
+		bounds.Max.X+capsule.Radius, bounds.Max.Y+capsule.Radius)
+}
+
+// Segment represents line segment a-b. It has no inside, particles collide with it when they
+// touch it by their radius
+type Segment struct {
+	Material
+	A, B pixel.Vec
+}
+
+// Contains reports false as segment has no inside
+func (segment *Segment) Contains(position pixel.Vec) bool {
+	return false
+}
+
+// ClosestPoint returns point of the segment closest to position
+func (segment *Segment) ClosestPoint(position pixel.Vec) pixel.Vec {
+	return closestPointOnSegment(position, segment.A, segment.B)
+}
+
+// Normal returns normal of the segment pointing towards position
+func (segment *Segment) Normal(position pixel.Vec) pixel.Vec {
+	_, normal := (&Capsule{A: segment.A, B: segment.B}).nearest(position)
+	return normal
+}
+
+// Move moves the segment by delta
+func (segment *Segment) Move(delta pixel.Vec) {
+	segment.A = segment.A.Add(delta)
+	segment.B = segment.B.Add(delta)
+}
+
+// Bounds returns bounding box of the segment
+func (segment *Segment) Bounds() pixel.Rect {
+	return pointsBounds(segment.A, segment.B)
+}
+
+// boxNearest returns point of the boundary of box given by min and max closest to position and
+// outward normal at this point
+func boxNearest(position pixel.Vec, min pixel.Vec, max pixel.Vec) (pixel.Vec, pixel.Vec) {
+	clamped := pixel.V(math.Min(math.Max(position.X, min.X), max.X),
+		math.Min(math.Max(position.Y, min.Y), max.Y))
+	if clamped != position {
+		return clamped, position.Sub(clamped).Unit()
+	}
+
+	// inside of the box the point is moved to the nearest face
+	faces := []struct {
+		distance float64
+		normal   pixel.Vec
+	}{
+		{position.X - min.X, pixel.V(-1, 0)},
+		{max.X - position.X, pixel.V(1, 0)},
+		{position.Y - min.Y, pixel.V(0, -1)},
+		{max.Y - position.Y, pixel.V(0, 1)},
+	}
+	nearest := faces[0]
+	for _, face := range faces[1:] {
+		if face.distance < nearest.distance {
+			nearest = face
+		}
+	}
+	return position.Add(nearest.normal.Scaled(nearest.distance)), nearest.normal
+}
+
+// AxisAlignedBox represents box with sides parallel to the axes
+type AxisAlignedBox struct {
+	Material
+	Min, Max pixel.Vec
+}
+
+// Contains reports whether position is inside of the box
+func (box *AxisAlignedBox) Contains(position pixel.Vec) bool {
+	return position.X >= box.Min.X && position.X <= box.Max.X &&
+		position.Y >= box.Min.Y && position.Y <= box.Max.Y
+}
+
+// ClosestPoint returns point of the boundary of the box closest to position
+func (box *AxisAlignedBox) ClosestPoint(position pixel.Vec) pixel.Vec {
+	point, _ := boxNearest(position, box.Min, box.Max)
+	return point
+}
+
+// Normal returns outward normal of the box at the point closest to position
+func (box *AxisAlignedBox) Normal(position pixel.Vec) pixel.Vec {
+	_, normal := boxNearest(position, box.Min, box.Max)
+	return normal
+}
+
+// Move moves the box by delta
+func (box *AxisAlignedBox) Move(delta pixel.Vec) {
+	box.Min = box.Min.Add(delta)
+	box.Max = box.Max.Add(delta)
+}
+
+// Bounds returns the box itself
+func (box *AxisAlignedBox) Bounds() pixel.Rect {
+	return pixel.Rect{Min: box.Min, Max: box.Max}
+}
+
+// OrientedBox represents box rotated by angle in radians around its centre
+type OrientedBox struct {
+	Material
+	Center   pixel.Vec
+	HalfSize pixel.Vec
+	Angle    float64
+}
+
+// local returns position in the frame of the box
+func (box *OrientedBox) local(position pixel.Vec) pixel.Vec {
+	return position.Sub(box.Center).Rotated(-box.Angle)
+}
+
+// Contains reports whether position is inside of the box
+func (box *OrientedBox) Contains(position pixel.Vec) bool {
+	local := box.local(position)
+	return math.Abs(local.X) <= box.HalfSize.X && math.Abs(local.Y) <= box.HalfSize.Y
+}
+
+// ClosestPoint returns point of the boundary of the box closest to position
+func (box *OrientedBox) ClosestPoint(position pixel.Vec) pixel.Vec {
+	point, _ := boxNearest(box.local(position), box.HalfSize.Scaled(-1), box.HalfSize)
+	return point.Rotated(box.Angle).Add(box.Center)
+}
+
+// Normal returns outward normal of the box at the point closest to position
+func (box *OrientedBox) Normal(position pixel.Vec) pixel.Vec {
+	_, normal := boxNearest(box.local(position), box.HalfSize.Scaled(-1), box.HalfSize)
+	return normal.Rotated(box.Angle)
+}
+
+// Move moves the box by delta
+func (box *OrientedBox) Move(delta pixel.Vec) {
+	box.Center = box.Center.Add(delta)
+}
+
+// Vertices returns corners of the box in counter-clockwise order
+func (box *OrientedBox) Vertices() []pixel.Vec {
+	vertices := []pixel.Vec{
+		pixel.V(-box.HalfSize.X, -box.HalfSize.Y),
+		pixel.V(box.HalfSize.X, -box.HalfSize.Y),
+		box.HalfSize,
+		pixel.V(-box.HalfSize.X, box.HalfSize.Y),
+	}
+	for i := range vertices {
+		vertices[i] = vertices[i].Rotated(box.Angle).Add(box.Center)
+	}
+	return vertices
+}
+
+// Bounds returns bounding box of the rotated box
+func (box *OrientedBox) Bounds() pixel.Rect {
+	return pointsBounds(box.Vertices()...)
+}
+
+// sweepTolerance is distance in pixels from a collider at which swept circles touch it
+const sweepTolerance = 0.01
+
+// signedDistance returns distance in pixels from position to the boundary of collider, negative
+// inside of it
+func signedDistance(collider Collider, position pixel.Vec) float64 {
+	distance := collider.ClosestPoint(position).To(position).Len()
+	if collider.Contains(position) {
+		return -distance
+	}
+	return distance
+}
+
+// timeOfImpact returns fraction of the path from-to at which circle with radius in pixels moving
+// along it touches the collider. Circles which touch the collider at the start and move away
+// from it do not hit it
+func timeOfImpact(collider Collider, from pixel.Vec, to pixel.Vec, radius float64) (float64, bool) {
+	const maxIterations = 64
+
+	path := from.To(to)
+	length := path.Len()
+	if length == 0 {
+		return 0, false
+	}
+
+	// conservative advancement, the circle moves by its distance from the collider which it can
+	// not cross, until the distance is within the tolerance
+	t := 0.0
+	for i := 0; i < maxIterations; i++ {
+		position := from.Add(path.Scaled(t))
+		distance := signedDistance(collider, position) - radius
+		if distance < sweepTolerance {
+			if t == 0 && collider.Normal(position).Dot(path) >= 0 {
+				return 0, false
+			}
+			return t, true
+		}
+		t += distance / length
+		if t > 1 {
+			return 0, false
+		}
+	}
+	return 0, false
+}
+
+// maxCollisionSubsteps limits number of collisions of a particle with colliders in one step
+const maxCollisionSubsteps = 4
+
+// bounce changes speed of the particle hitting a collider with outward unit normal. Speed is
+// decomposed to the normal and the tangential part, the normal part is reversed and scaled by
+// restitution of the collider and the tangential part is slowed down by Coulomb friction, whose
+// impulse is at most friction coefficient times the normal impulse
+func bounce(p *Particle, normal pixel.Vec, material *Material) {
+	approach := p.Speed.Dot(normal)
+	if approach >= 0 {
+		return
+	}
+
+	tangent := p.Speed.Sub(normal.Scaled(approach))
+	// change of the normal speed, Δv_n = -(1 + e) * v_n
+	normalChange := -(1 + material.Restitution) * approach
+	// |Δv_t| <= μ * Δv_n, the particle sticks when friction stops it
+	if speed := tangent.Len(); speed > 0 {
+		tangent = tangent.Scaled(math.Max(0, 1-material.Friction*normalChange/speed))
+	}
+	p.Speed = tangent.Add(normal.Scaled(-material.Restitution * approach))
+}
+
+// collideWithColliders bounces the particle off colliders of the scene and returns the position
+// where the particle ends up. The path of the step is swept, so fast particles do not tunnel
+// through thin colliders. At the time of impact the particle bounces and the rest of the step
+// continues with the new speed
+func collideWithColliders(p *Particle, newPosition pixel.Vec, dt float64, scene *Scene) pixel.Vec {
+	radius := p.Radius * PixelsPerMeter
+	from, remaining := p.Position, dt
+	collided := false
+	for substep := 0; ; substep++ {
+		t, collider := scene.Sweep(from, newPosition, radius)
+		if collider == nil {
+			break
+		}
+		collided = true
+
+		contact := from.Add(from.To(newPosition).Scaled(t))
+		bounce(p, collider.Normal(contact), collider.Surface())
+		remaining *= 1 - t
+		from = contact
+		newPosition = contact.Add(p.Speed.Scaled(remaining * PixelsPerMeter))
+		if substep == maxCollisionSubsteps-1 {
+			// the rest of the path is not swept, so the particle stays at the contact
+			newPosition = contact
+			break
+		}
+	}
+
+	// overlaps which were not swept into, e.g. by moving colliders, are projected to the surface
+	scene.Near(newPosition, radius, func(collider Collider) {
+		if signedDistance(collider, newPosition) >= radius-sweepTolerance {
+			return
+		}
+		collided = true
+
+		normal := collider.Normal(newPosition)
+		bounce(p, normal, collider.Surface())
+		newPosition = collider.ClosestPoint(newPosition).Add(normal.Scaled(radius))
+	})
+
+	if collided {
+		p.Position = newPosition
+		p.resetHistory(dt)
+	}
+	return newPosition
+}
@@ -0,0 +1,191 @@
+package sim
+
+import (
+	"math"
+	"testing"
+
+	"github.com/faiface/pixel"
+)
+
+// TestOcolliders tests closest points and normals of colliders for points outside and inside
+func TestOcolliders(t *testing.T) {
+	cases := []struct {
+		name     string
+		collider Collider
+		position pixel.Vec
+		inside   bool
+		closest  pixel.Vec
+		normal   pixel.Vec
+	}{
+		{"circle", &Circle{Position: pixel.V(0, 0), Radius: 10},
+			pixel.V(0, 20), false, pixel.V(0, 10), pixel.V(0, 1)},
+		{"circle", &Circle{Position: pixel.V(0, 0), Radius: 10},
+			pixel.V(-5, 0), true, pixel.V(-10, 0), pixel.V(-1, 0)},
+		{"polygon", &ConvexPolygon{Vertices: []pixel.Vec{
+			pixel.V(0, 0), pixel.V(20, 0), pixel.V(0, 20)}},
+			pixel.V(10, -5), false, pixel.V(10, 0), pixel.V(0, -1)},
+		{"polygon", &ConvexPolygon{Vertices: []pixel.Vec{
+			pixel.V(0, 0), pixel.V(20, 0), pixel.V(0, 20)}},
+			pixel.V(-3, -4), false, pixel.V(0, 0), pixel.V(-0.6, -0.8)},
+		{"polygon", &ConvexPolygon{Vertices: []pixel.Vec{
+			pixel.V(0, 0), pixel.V(20, 0), pixel.V(0, 20)}},
+			pixel.V(2, 5), true, pixel.V(0, 5), pixel.V(-1, 0)},
+		{"segment", &Segment{A: pixel.V(-10, 0), B: pixel.V(10, 0)},
+			pixel.V(5, -3), false, pixel.V(5, 0), pixel.V(0, -1)},
+		{"segment", &Segment{A: pixel.V(-10, 0), B: pixel.V(10, 0)},
+			pixel.V(14, 3), false, pixel.V(10, 0), pixel.V(0.8, 0.6)},
+		{"box", &AxisAlignedBox{Min: pixel.V(0, 0), Max: pixel.V(20, 10)},
+			pixel.V(25, 5), false, pixel.V(20, 5), pixel.V(1, 0)},
+		{"box", &AxisAlignedBox{Min: pixel.V(0, 0), Max: pixel.V(20, 10)},
+			pixel.V(5, 8), true, pixel.V(5, 10), pixel.V(0, 1)},
+		{"oriented box", &OrientedBox{HalfSize: pixel.V(10, 5), Angle: math.Pi / 2},
+			pixel.V(0, 15), false, pixel.V(0, 10), pixel.V(0, 1)},
+		{"oriented box", &OrientedBox{HalfSize: pixel.V(10, 5), Angle: math.Pi / 2},
+			pixel.V(-4, 0), true, pixel.V(-5, 0), pixel.V(-1, 0)},
+		{"capsule", &Capsule{A: pixel.V(-10, 0), B: pixel.V(10, 0), Radius: 5},
+			pixel.V(0, 8), false, pixel.V(0, 5), pixel.V(0, 1)},
+		{"capsule", &Capsule{A: pixel.V(-10, 0), B: pixel.V(10, 0), Radius: 5},
+			pixel.V(12, 0), true, pixel.V(15, 0), pixel.V(1, 0)},
+	}
+
+	for _, c := range cases {
+		if inside := c.collider.Contains(c.position); inside != c.inside {
+			t.Errorf("Collider %s: Expected %v contained=%v got %v",
+				c.name, c.position, c.inside, inside)
+		}
+		if closest := c.collider.ClosestPoint(c.position); closest.To(c.closest).Len() > 1e-9 {
+			t.Errorf("Collider %s: Expected closest point to %v at %v got %v",
+				c.name, c.position, c.closest, closest)
+		}
+		if normal := c.collider.Normal(c.position); normal.To(c.normal).Len() > 1e-9 {
+			t.Errorf("Collider %s: Expected normal at %v of %v got %v",
+				c.name, c.position, c.normal, normal)
+		}
+	}
+}
+
+// TestOsegment tests a particle falling on a segment stays on top of it
+func TestOsegment(t *testing.T) {
+	segment := &Segment{A: pixel.V(-100, 0), B: pixel.V(100, 0)}
+	p := Particle{
+		Position: pixel.V(0, 50),
+		Mass:     1,
+		Radius:   ParticleRadius,
+		Forces:   &ForceField{UniformGravity{Acceleration: Gravity}},
+	}
+
+	scene := NewScene(segment)
+	const dt = 0.001
+	for i := 0; i < 2000; i++ {
+		newPosition := ExplicitEulerIntegrator{}.Step(&p, dt)
+		p.Position = collideWithColliders(&p, newPosition, dt, scene)
+		if p.Position.Y < 0 {
+			t.Fatalf("Segment: Expected particle above the segment got %v", p.Position)
+		}
+	}
+	if p.Position.Y > 10 {
+		t.Errorf("Segment: Expected particle resting on the segment got %v", p.Position)
+	}
+}
+
+// TestOtimeOfImpact tests time of impact of a circle moving towards colliders
+func TestOtimeOfImpact(t *testing.T) {
+	cases := []struct {
+		name     string
+		collider Collider
+		from, to pixel.Vec
+		hit      bool
+		toi      float64
+	}{
+		// the circle of radius 5 touches the circle of radius 10 at x = -15
+		{"circle", &Circle{Radius: 10}, pixel.V(-100, 0), pixel.V(100, 0), true, 0.425},
+		{"circle", &Circle{Radius: 10}, pixel.V(-100, 20), pixel.V(100, 20), false, 0},
+		{"segment", &Segment{A: pixel.V(0, -10), B: pixel.V(0, 10)},
+			pixel.V(-45, 0), pixel.V(55, 0), true, 0.4},
+		{"box", &AxisAlignedBox{Min: pixel.V(0, 0), Max: pixel.V(1, 100)},
+			pixel.V(-25, 50), pixel.V(175, 50), true, 0.1},
+		// touching circle moving away does not hit
+		{"box", &AxisAlignedBox{Min: pixel.V(0, 0), Max: pixel.V(1, 100)},
+			pixel.V(-5, 50), pixel.V(-100, 50), false, 0},
+		{"capsule", &Capsule{A: pixel.V(-10, 0), B: pixel.V(10, 0), Radius: 5},
+			pixel.V(0, 110), pixel.V(0, -90), true, 0.5},
+	}
+
+	for _, c := range cases {
+		toi, hit := timeOfImpact(c.collider, c.from, c.to, 5)
+		if hit != c.hit || math.Abs(toi-c.toi) > 1e-3 {
+			t.Errorf("Time of impact %s from %v to %v: Expected %v at %f got %v at %f",
+				c.name, c.from, c.to, c.hit, c.toi, hit, toi)
+		}
+	}
+}
+
+// TestOtunnelling tests fast particles bounce off thin colliders instead of passing through them
+func TestOtunnelling(t *testing.T) {
+	elastic := Material{Restitution: 1}
+	colliders := []Collider{
+		&Segment{Material: elastic, A: pixel.V(0, -100), B: pixel.V(0, 100)},
+		&AxisAlignedBox{Material: elastic, Min: pixel.V(0, -100), Max: pixel.V(1, 100)},
+		&OrientedBox{Material: elastic, HalfSize: pixel.V(0.5, 100), Angle: 0.2},
+		&Capsule{Material: elastic, A: pixel.V(0, -100), B: pixel.V(0, 100), Radius: 0.5},
+	}
+
+	for _, integrator := range Integrators() {
+		for _, collider := range colliders {
+			for _, speed := range []float64{20, 100, 500} {
+				scene := NewScene(collider)
+				p := Particle{
+					Position: pixel.V(-30, 3),
+					Speed:    pixel.V(speed, 0),
+					Mass:     1,
+					Radius:   ParticleRadius,
+					Forces:   &ForceField{},
+				}
+				const dt = 1.0 / 30
+				p.resetHistory(dt)
+
+				for i := 0; i < 30; i++ {
+					newPosition := integrator.Step(&p, dt)
+					p.Position = collideWithColliders(&p, newPosition, dt, scene)
+					if p.Position.X > 0 {
+						t.Fatalf("%s collider %T speed %.0f m/s: Expected particle in front of "+
+							"the collider got %v", integrator.Name(), collider, speed, p.Position)
+					}
+				}
+				if p.Speed.X >= 0 {
+					t.Errorf("%s collider %T speed %.0f m/s: Expected particle bouncing back got "+
+						"speed %v", integrator.Name(), collider, speed, p.Speed)
+				}
+			}
+		}
+	}
+}
+
+// TestObounce tests decomposition of the speed of a particle hitting a collider to the normal and
+// the tangential part
+func TestObounce(t *testing.T) {
+	cases := []struct {
+		material Material
+		speed    pixel.Vec
+		eSpeed   pixel.Vec
+	}{
+		{Material{Restitution: 1}, pixel.V(3, -4), pixel.V(3, 4)},
+		{Material{Restitution: 0.5}, pixel.V(3, -4), pixel.V(3, 2)},
+		{Material{Restitution: 0}, pixel.V(3, -4), pixel.V(3, 0)},
+		// friction impulse 0.1 * (1 + 0.5) * 4 slows down the tangential speed
+		{Material{Restitution: 0.5, Friction: 0.1}, pixel.V(3, -4), pixel.V(2.4, 2)},
+		// friction stops the particle when it is strong enough
+		{Material{Restitution: 0.5, Friction: 1}, pixel.V(3, -4), pixel.V(0, 2)},
+		// particle leaving the collider is not changed
+		{Material{Restitution: 0.5, Friction: 1}, pixel.V(3, 4), pixel.V(3, 4)},
+	}
+
+	for _, c := range cases {
+		p := Particle{Speed: c.speed}
+		bounce(&p, pixel.V(0, 1), &c.material)
+		if p.Speed.To(c.eSpeed).Len() > 1e-12 {
+			t.Errorf("Bounce %+v speed %v: Expected speed of %v got %v",
+				c.material, c.speed, c.eSpeed, p.Speed)
+		}
+	}
+}
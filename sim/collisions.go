@@ -0,0 +1,83 @@
+package sim
+
+// ParticleCollisions represents collisions between particles of a particle system. Collisions are
+// elastic for restitution of 1 and perfectly inelastic for restitution of 0
+type ParticleCollisions struct {
+	Enabled     bool
+	Restitution *Parameter
+	hash        *SpatialHash
+}
+
+// Resolve finds all pairs of overlapping particles, separates them and exchanges their momentum.
+// History of positions of the colliding particles is rebuilt for time-step dt in s
+func (collisions *ParticleCollisions) Resolve(particles *Particles, dt float64) {
+	if !collisions.Enabled || particles.Len() == 0 {
+		return
+	}
+
+	// cells are as large as the largest particle so all colliding pairs are in neighbouring cells
+	maxRadius := 0.0
+	for _, radius := range particles.radius {
+		if radius > maxRadius {
+			maxRadius = radius
+		}
+	}
+	if maxRadius == 0 {
+		return
+	}
+
+	if collisions.hash == nil {
+		collisions.hash = NewSpatialHash(2 * maxRadius * PixelsPerMeter)
+	}
+	collisions.hash.Clear(2 * maxRadius * PixelsPerMeter)
+	for i, position := range particles.position {
+		collisions.hash.Insert(i, position)
+	}
+
+	for i := range particles.position {
+		collisions.hash.Neighbours(particles.position[i], func(j int) {
+			// every pair is resolved once
+			if j <= i {
+				return
+			}
+			if collisions.collide(particles, i, j) {
+				particles.resetHistory(i, dt)
+				particles.resetHistory(j, dt)
+			}
+		})
+	}
+}
+
+// collide resolves collision of particles with indices a and b and reports whether they collided
+func (collisions *ParticleCollisions) collide(particles *Particles, a int, b int) bool {
+	position, speed := particles.position, particles.speed
+	d := position[b].Sub(position[a])
+	distance := d.Len()
+	minDistance := (particles.radius[a] + particles.radius[b]) * PixelsPerMeter
+	if distance >= minDistance || distance == 0 {
+		return false
+	}
+
+	// particles without mass are static, two static particles are left overlapping
+	inverseMassA, inverseMassB := particles.inverseMass(a), particles.inverseMass(b)
+	inverseMass := inverseMassA + inverseMassB
+	if inverseMass == 0 {
+		return false
+	}
+	normal := d.Scaled(1 / distance)
+
+	// particles are pushed apart in proportion to their inverse mass so the centre of mass stays
+	overlap := minDistance - distance
+	position[a] = position[a].Sub(normal.Scaled(overlap * inverseMassA / inverseMass))
+	position[b] = position[b].Add(normal.Scaled(overlap * inverseMassB / inverseMass))
+
+	// j = -(1 + e) * (v_rel . n) / (1/m_a + 1/m_b)
+	approach := speed[b].Sub(speed[a]).Dot(normal)
+	if approach < 0 {
+		impulse := -(1 + collisions.Restitution.Value) * approach / inverseMass
+		speed[a] = speed[a].Sub(normal.Scaled(impulse * inverseMassA))
+		speed[b] = speed[b].Add(normal.Scaled(impulse * inverseMassB))
+	}
+
+	return true
+}
@@ -0,0 +1,119 @@
+package sim
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/faiface/pixel"
+)
+
+// momentum returns total momentum of particles in kg*m*s^{-1}
+func momentum(particles []Particle) pixel.Vec {
+	total := pixel.ZV
+	for _, p := range particles {
+		total = total.Add(p.Speed.Scaled(p.Mass))
+	}
+	return total
+}
+
+// kineticEnergy returns total kinetic energy of particles in J
+func kineticEnergy(particles []Particle) float64 {
+	total := 0.0
+	for _, p := range particles {
+		total += p.Mass * p.Speed.Dot(p.Speed) / 2
+	}
+	return total
+}
+
+// TestCmomentum tests conservation of momentum of colliding particles
+func TestCmomentum(t *testing.T) {
+	for _, restitution := range []float64{0, 0.5, 1} {
+		random := rand.New(rand.NewSource(1))
+		particles := NewParticles(0, nil)
+		for i := 0; i < 500; i++ {
+			particles.Add(Particle{
+				Position: pixel.V(random.Float64()*100, random.Float64()*100),
+				Speed:    pixel.V(random.NormFloat64(), random.NormFloat64()),
+				Mass:     0.01 + random.Float64(),
+				Radius:   0.01 + random.Float64()*0.02,
+			})
+		}
+
+		collisions := ParticleCollisions{
+			Enabled:     true,
+			Restitution: &Parameter{Value: restitution},
+		}
+
+		eMomentum := momentum(particles.slice())
+		collisions.Resolve(particles, 0.01)
+
+		if diff := momentum(particles.slice()).To(eMomentum).Len(); diff > 1e-12 {
+			t.Errorf(
+				"Particle collisions restitution=%.1f: Expected momentum of %f got %f",
+				restitution, eMomentum, momentum(particles.slice()),
+			)
+		}
+	}
+}
+
+// TestCheadOn tests head-on collision of two particles
+func TestCheadOn(t *testing.T) {
+	collide := func(restitution float64) []Particle {
+		particles := NewParticles(0, nil)
+		particles.Add(Particle{Position: pixel.V(0, 0), Speed: pixel.V(2, 0), Mass: 1, Radius: 0.02})
+		particles.Add(Particle{Position: pixel.V(3, 0), Speed: pixel.V(-1, 0), Mass: 2, Radius: 0.02})
+		collisions := ParticleCollisions{
+			Enabled:     true,
+			Restitution: &Parameter{Value: restitution},
+		}
+		collisions.Resolve(particles, 0.01)
+		return particles.slice()
+	}
+
+	// elastic collision conserves kinetic energy
+	particles := collide(1)
+	if e := kineticEnergy(particles); math.Abs(e-3) > 1e-12 {
+		t.Errorf("Elastic collision: Expected kinetic energy of %f got %f", 3.0, e)
+	}
+
+	// perfectly inelastic collision leaves particles with the same speed
+	particles = collide(0)
+	if particles[0].Speed.To(particles[1].Speed).Len() > 1e-12 {
+		t.Errorf(
+			"Inelastic collision: Expected equal speeds got %f and %f",
+			particles[0].Speed, particles[1].Speed,
+		)
+	}
+
+	// particles are separated
+	if d := particles[0].Position.To(particles[1].Position).Len(); d < 4-1e-9 {
+		t.Errorf("Inelastic collision: Expected distance of %f got %f", 4.0, d)
+	}
+}
+
+// TestCstatic tests that particle without mass is static and the other particle bounces off it
+func TestCstatic(t *testing.T) {
+	particles := NewParticles(0, nil)
+	particles.Add(Particle{Position: pixel.V(0, 0), Speed: pixel.V(2, 0), Mass: 1, Radius: 0.02})
+	particles.Add(Particle{Position: pixel.V(3, 0), Speed: pixel.V(0, 0), Mass: 0, Radius: 0.02})
+	collisions := ParticleCollisions{Enabled: true, Restitution: &Parameter{Value: 1}}
+	collisions.Resolve(particles, 0.01)
+
+	if particles.Position(1) != pixel.V(3, 0) || particles.Speed(1) != pixel.ZV {
+		t.Errorf("Static particle: Expected position of %v got %v", pixel.V(3, 0),
+			particles.Position(1))
+	}
+	if particles.Speed(0) != pixel.V(-2, 0) {
+		t.Errorf("Static particle: Expected speed of %v got %v", pixel.V(-2, 0), particles.Speed(0))
+	}
+
+	// two static particles stay where they are
+	particles.Set(0, &Particle{Position: pixel.V(0, 0), Radius: 0.02})
+	collisions.Resolve(particles, 0.01)
+	for i := 0; i < particles.Len(); i++ {
+		if p := particles.Position(i); math.IsNaN(p.X) || math.IsNaN(p.Y) {
+			t.Errorf("Static particles: Expected particle %d in place got %v", i, p)
+		}
+	}
+}
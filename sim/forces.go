@@ -0,0 +1,278 @@
+package sim
+
+import (
+	"math"
+
+	"github.com/faiface/pixel"
+)
+
+// Force represents a force acting on a particle. Particles of a particle system are advanced by
+// several goroutines at once, so forces must not change any state when they are evaluated
+type Force interface {
+	// Force returns force in N acting on particle p in a given state. Position is in pixels and
+	// speed in m*s^{-1}
+	Force(p *Particle, position pixel.Vec, speed pixel.Vec) pixel.Vec
+	// Jacobian returns partial derivatives of the force with respect to position in N*px^{-1} and
+	// with respect to speed in N*s*m^{-1}
+	Jacobian(p *Particle, position pixel.Vec, speed pixel.Vec) (Matrix2, Matrix2)
+}
+
+// ForceField represents set of forces acting on a particle which are summed together
+type ForceField []Force
+
+// DefaultForces is force field acting on particles which are not attached to any other field
+var DefaultForces = ForceField{UniformGravity{Acceleration: Gravity}}
+
+// Force returns sum of all forces of the field in N
+func (field ForceField) Force(p *Particle, position pixel.Vec, speed pixel.Vec) pixel.Vec {
+	total := pixel.ZV
+	for _, force := range field {
+		total = total.Add(force.Force(p, position, speed))
+	}
+	return total
+}
+
+// Jacobian returns sum of partial derivatives of all forces of the field
+func (field ForceField) Jacobian(
+	p *Particle,
+	position pixel.Vec,
+	speed pixel.Vec) (Matrix2, Matrix2) {
+	var dPosition, dSpeed Matrix2
+	for _, force := range field {
+		fPosition, fSpeed := force.Jacobian(p, position, speed)
+		dPosition = dPosition.Add(fPosition)
+		dSpeed = dSpeed.Add(fSpeed)
+	}
+	return dPosition, dSpeed
+}
+
+// Spring represents damped spring between a particle and a fixed anchor point
+type Spring struct {
+	Anchor     pixel.Vec // in pixels
+	RestLength float64   // in m
+	Stiffness  float64   // in N*m^{-1}
+	Damping    float64   // in N*s*m^{-1}
+}
+
+// Force returns force of the spring in N
+func (spring Spring) Force(p *Particle, position pixel.Vec, speed pixel.Vec) pixel.Vec {
+	// F = -k * (|d| - l) * d/|d| - c*v
+	d := position.Sub(spring.Anchor).Scaled(1 / PixelsPerMeter)
+	length := d.Len()
+
+	force := speed.Scaled(-spring.Damping)
+	if length > 0 {
+		force = force.Add(d.Scaled(-spring.Stiffness * (length - spring.RestLength) / length))
+	}
+	return force
+}
+
+// Jacobian returns partial derivatives of the force of the spring
+func (spring Spring) Jacobian(p *Particle, position pixel.Vec, speed pixel.Vec) (Matrix2, Matrix2) {
+	// dF/dd = -k * ((1 - l/|d|) * (I - n*n^T) + n*n^T) where n = d/|d|
+	d := position.Sub(spring.Anchor).Scaled(1 / PixelsPerMeter)
+	length := d.Len()
+
+	dPosition := Identity2.Scaled(-spring.Stiffness)
+	if length > 0 {
+		nn := Outer2(d.Scaled(1/length), d.Scaled(1/length))
+		dPosition = Identity2.Sub(nn).Scaled(1 - spring.RestLength/length).Add(nn).Scaled(
+			-spring.Stiffness)
+	}
+
+	// position in the derivative is measured in pixels
+	return dPosition.Scaled(1 / PixelsPerMeter), Identity2.Scaled(-spring.Damping)
+}
+
+// UniformGravity represents homogeneous gravitational field
+type UniformGravity struct {
+	Acceleration pixel.Vec // in m*s^{-2}
+}
+
+// Force returns gravitational force in N
+func (gravity UniformGravity) Force(p *Particle, position pixel.Vec, speed pixel.Vec) pixel.Vec {
+	return gravity.Acceleration.Scaled(p.Mass)
+}
+
+// Jacobian returns partial derivatives of the gravitational force which are zero
+func (gravity UniformGravity) Jacobian(
+	p *Particle,
+	position pixel.Vec,
+	speed pixel.Vec) (Matrix2, Matrix2) {
+	return Matrix2{}, Matrix2{}
+}
+
+// DragMode is enum for choosing model of aerodynamic drag
+type DragMode int
+
+const (
+	// NoDrag turns the drag off
+	NoDrag DragMode = iota
+	// StokesDrag is linear drag of a sphere in viscous fluid given by Stokes' law
+	StokesDrag DragMode = iota
+	// NewtonDrag is quadratic drag of a body moving fast through fluid
+	NewtonDrag DragMode = iota
+)
+
+// Drag represents aerodynamic drag force acting against the speed of a particle
+type Drag struct {
+	Mode      *DragMode
+	Viscosity *Parameter // dynamic viscosity of the fluid in Pa*s used by StokesDrag
+	Density   *Parameter // density of the fluid in kg*m^{-3} used by NewtonDrag
+}
+
+// Force returns drag force in N
+func (drag Drag) Force(p *Particle, position pixel.Vec, speed pixel.Vec) pixel.Vec {
+	switch *drag.Mode {
+	case StokesDrag:
+		// F = -6*π*μ*r*v
+		return speed.Scaled(-drag.stokesCoefficient(p))
+	case NewtonDrag:
+		// F = -(1/2)*ρ*C_d*A*|v|*v
+		return speed.Scaled(-drag.newtonCoefficient(p) * speed.Len())
+	default:
+		return pixel.ZV
+	}
+}
+
+// Jacobian returns partial derivatives of the drag force
+func (drag Drag) Jacobian(p *Particle, position pixel.Vec, speed pixel.Vec) (Matrix2, Matrix2) {
+	switch *drag.Mode {
+	case StokesDrag:
+		return Matrix2{}, Identity2.Scaled(-drag.stokesCoefficient(p))
+	case NewtonDrag:
+		// dF/dv = -(1/2)*ρ*C_d*A*(|v|*I + v*v^T/|v|)
+		length := speed.Len()
+		if length == 0 {
+			return Matrix2{}, Matrix2{}
+		}
+		return Matrix2{}, Identity2.Scaled(length).Add(Outer2(speed, speed).Scaled(1 / length)).Scaled(
+			-drag.newtonCoefficient(p))
+	default:
+		return Matrix2{}, Matrix2{}
+	}
+}
+
+// stokesCoefficient returns coefficient of the linear drag of a particle in N*s*m^{-1}
+func (drag Drag) stokesCoefficient(p *Particle) float64 {
+	return 6 * math.Pi * drag.Viscosity.Value * p.Radius
+}
+
+// newtonCoefficient returns coefficient of the quadratic drag of a particle in N*s^{2}*m^{-2}
+func (drag Drag) newtonCoefficient(p *Particle) float64 {
+	return 0.5 * drag.Density.Value * p.DragCoefficient * math.Pi * p.Radius * p.Radius
+}
+
+// Falloff is enum for choosing how the force of an attractor decreases with distance
+type Falloff int
+
+const (
+	// InverseSquare is falloff of the force inversely proportional to the square of the distance
+	InverseSquare Falloff = iota
+	// InverseLinear is falloff of the force inversely proportional to the distance
+	InverseLinear Falloff = iota
+)
+
+// Attractor represents point that attracts particles with force decreasing with distance.
+// Negative strength makes the point repel particles. Softening limits the force close to the point
+// and no force acts on particles further than cutoff
+type Attractor struct {
+	Position  pixel.Vec // in pixels
+	Strength  float64   // in N*m^{2} for InverseSquare and N*m for InverseLinear
+	Falloff   Falloff
+	Softening float64 // in m
+	Cutoff    float64 // in m, zero for unlimited range
+}
+
+// Force returns force of the attractor in N
+func (attractor Attractor) Force(p *Particle, position pixel.Vec, speed pixel.Vec) pixel.Vec {
+	// F = -s * d/(|d|^2 + ε^2)^{n/2} where n = 3 for InverseSquare and n = 2 for InverseLinear
+	d, softened, ok := attractor.distance(position)
+	if !ok {
+		return pixel.ZV
+	}
+	return d.Scaled(-attractor.Strength / math.Pow(softened, attractor.exponent()))
+}
+
+// Jacobian returns partial derivatives of the force of the attractor
+func (attractor Attractor) Jacobian(
+	p *Particle,
+	position pixel.Vec,
+	speed pixel.Vec) (Matrix2, Matrix2) {
+	// dF/dd = -s * (I/s^n - n*d*d^T/s^{n+2}) where s = (|d|^2 + ε^2)^{1/2}
+	d, softened, ok := attractor.distance(position)
+	if !ok {
+		return Matrix2{}, Matrix2{}
+	}
+	n := attractor.exponent()
+	dPosition := Identity2.Scaled(math.Pow(softened, -n)).Sub(
+		Outer2(d, d).Scaled(n * math.Pow(softened, -n-2))).Scaled(-attractor.Strength)
+
+	// position in the derivative is measured in pixels
+	return dPosition.Scaled(1 / PixelsPerMeter), Matrix2{}
+}
+
+// distance returns vector from the attractor to the position in m and the softened distance.
+// When the position is out of reach of the attractor, false is returned
+func (attractor Attractor) distance(position pixel.Vec) (pixel.Vec, float64, bool) {
+	d := position.Sub(attractor.Position).Scaled(1 / PixelsPerMeter)
+	length := d.Len()
+	if attractor.Cutoff > 0 && length > attractor.Cutoff {
+		return pixel.ZV, 0, false
+	}
+
+	softened := math.Sqrt(length*length + attractor.Softening*attractor.Softening)
+	if softened == 0 {
+		return pixel.ZV, 0, false
+	}
+	return d, softened, true
+}
+
+// exponent returns power of the softened distance in the denominator of the force
+func (attractor Attractor) exponent() float64 {
+	if attractor.Falloff == InverseLinear {
+		return 2
+	}
+	return 3
+}
+
+// CustomForce represents force given by an arbitrary function. When jacobian is not given, partial
+// derivatives are approximated by central differences
+type CustomForce struct {
+	force    func(p *Particle, position pixel.Vec, speed pixel.Vec) pixel.Vec
+	jacobian func(p *Particle, position pixel.Vec, speed pixel.Vec) (Matrix2, Matrix2)
+}
+
+// Force returns value of the custom force in N
+func (custom CustomForce) Force(p *Particle, position pixel.Vec, speed pixel.Vec) pixel.Vec {
+	return custom.force(p, position, speed)
+}
+
+// Jacobian returns partial derivatives of the custom force
+func (custom CustomForce) Jacobian(
+	p *Particle,
+	position pixel.Vec,
+	speed pixel.Vec) (Matrix2, Matrix2) {
+	if custom.jacobian != nil {
+		return custom.jacobian(p, position, speed)
+	}
+
+	// differences are relative to the magnitude of the state with 1 px and 1 m*s^{-1} as minimum
+	hPosition := 1e-6 * math.Max(1, position.Len())
+	hSpeed := 1e-6 * math.Max(1, speed.Len())
+
+	derivative := func(f func(delta pixel.Vec) pixel.Vec, h float64) Matrix2 {
+		dx := f(pixel.V(h, 0)).Sub(f(pixel.V(-h, 0))).Scaled(1 / (2 * h))
+		dy := f(pixel.V(0, h)).Sub(f(pixel.V(0, -h))).Scaled(1 / (2 * h))
+		return Matrix2{XX: dx.X, XY: dy.X, YX: dx.Y, YY: dy.Y}
+	}
+
+	dPosition := derivative(func(delta pixel.Vec) pixel.Vec {
+		return custom.force(p, position.Add(delta), speed)
+	}, hPosition)
+	dSpeed := derivative(func(delta pixel.Vec) pixel.Vec {
+		return custom.force(p, position, speed.Add(delta))
+	}, hSpeed)
+
+	return dPosition, dSpeed
+}
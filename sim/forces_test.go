@@ -0,0 +1,109 @@
+package sim
+
+import (
+	"math"
+	"testing"
+
+	"github.com/faiface/pixel"
+)
+
+// TestFjacobian tests analytic partial derivatives of forces against central differences
+// calculated by CustomForce
+func TestFjacobian(t *testing.T) {
+	var (
+		position = pixel.V(130, -40)
+		speed    = pixel.V(2, -3)
+		p        = Particle{
+			Position:        position,
+			Speed:           speed,
+			Mass:            0.5,
+			Radius:          ParticleRadius,
+			DragCoefficient: 0.47,
+		}
+		stokes  = StokesDrag
+		newton  = NewtonDrag
+		density = Parameter{Value: 1.2}
+		viscous = Parameter{Value: 0.8}
+	)
+
+	for name, force := range map[string]Force{
+		"Spring": Spring{
+			Anchor:     pixel.V(20, 10),
+			RestLength: 0.5,
+			Stiffness:  80,
+			Damping:    2,
+		},
+		"Attractor": Attractor{Position: pixel.V(-50, 60), Strength: 3},
+		"SoftenedAttractor": Attractor{
+			Position:  pixel.V(-50, 60),
+			Strength:  -2,
+			Falloff:   InverseLinear,
+			Softening: 0.5,
+			Cutoff:    10,
+		},
+		"LinkSpring": LinkSpring{
+			other: &Particle{Position: pixel.V(20, 10), Speed: pixel.V(-1, 0.5)},
+			link:  &Link{restLength: 0.5, stiffness: 80, damping: 2},
+		},
+		"StokesDrag": Drag{Mode: &stokes, Viscosity: &viscous, Density: &density},
+		"NewtonDrag": Drag{Mode: &newton, Viscosity: &viscous, Density: &density},
+	} {
+		custom := CustomForce{force: force.Force}
+
+		ePosition, eSpeed := force.Jacobian(&p, position, speed)
+		dPosition, dSpeed := custom.Jacobian(&p, position, speed)
+
+		for _, pair := range [][2]Matrix2{{ePosition, dPosition}, {eSpeed, dSpeed}} {
+			e, d := pair[0], pair[1]
+			diff := e.Sub(d)
+			if math.Max(math.Max(math.Abs(diff.XX), math.Abs(diff.XY)),
+				math.Max(math.Abs(diff.YX), math.Abs(diff.YY))) > 1e-6 {
+				t.Errorf("%s Jacobian: Expected %v got %v", name, e, d)
+			}
+		}
+	}
+}
+
+// TestFcurlNoise tests that turbulence of the wind given by curl noise is divergence free
+func TestFcurlNoise(t *testing.T) {
+	const eps = 1e-3
+
+	for _, point := range []pixel.Vec{pixel.V(0.3, 0.7), pixel.V(12.1, -3.4), pixel.V(-7.9, 5.5)} {
+		x, y := point.XY()
+		for _, z := range []float64{0, 0.4, 2.7} {
+			right, _ := curlNoise(x+eps, y, z)
+			left, _ := curlNoise(x-eps, y, z)
+			_, up := curlNoise(x, y+eps, z)
+			_, down := curlNoise(x, y-eps, z)
+
+			if divergence := (right-left)/(2*eps) + (up-down)/(2*eps); math.Abs(divergence) > 1e-3 {
+				t.Errorf("Curl noise at %v, %f: Expected zero divergence got %f", point, z, divergence)
+			}
+		}
+	}
+}
+
+// TestFremove tests that only forces added as a pointer are removed from a particle system and
+// that forces which are not comparable do not panic
+func TestFremove(t *testing.T) {
+	attractor := &Attractor{Position: pixel.V(10, 10), Strength: 1}
+	custom := CustomForce{force: UniformGravity{Acceleration: Gravity}.Force}
+
+	particleSystem := ParticleSystem{}
+	particleSystem.AddForce(custom)
+	particleSystem.AddForce(attractor)
+	particleSystem.AddForce(UniformGravity{Acceleration: Gravity})
+
+	if particleSystem.RemoveForce(custom) {
+		t.Errorf("Remove force by value: Expected force to stay got it removed")
+	}
+	if !particleSystem.RemoveForce(attractor) {
+		t.Errorf("Remove force by pointer: Expected force to be removed got it kept")
+	}
+	if particleSystem.RemoveForce(attractor) {
+		t.Errorf("Remove force twice: Expected nothing to remove got a force removed")
+	}
+	if forces := *particleSystem.Forces(); len(forces) != 2 {
+		t.Errorf("Remove force: Expected %d forces got %d", 2, len(forces))
+	}
+}
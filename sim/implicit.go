@@ -0,0 +1,97 @@
+package sim
+
+import "github.com/faiface/pixel"
+
+const (
+	// maximal number of Newton iterations solving one implicit step
+	newtonMaxIterations = 20
+	// Newton iterations stop when the change of speed gets below this value in m*s^{-1}
+	newtonTolerance = 1e-10
+)
+
+// ImplicitEulerIntegrator calculates new position of a particle based on backward Euler method
+type ImplicitEulerIntegrator struct{}
+
+// ImplicitMidpointIntegrator calculates new position of a particle based on implicit midpoint
+// method
+type ImplicitMidpointIntegrator struct{}
+
+func init() {
+	RegisterIntegrator(ImplicitEulerIntegrator{})
+	RegisterIntegrator(ImplicitMidpointIntegrator{})
+}
+
+// Name returns name of the integration method
+func (ImplicitEulerIntegrator) Name() string {
+	return "Implicit Euler"
+}
+
+// Order returns order of accuracy of the integration method
+func (ImplicitEulerIntegrator) Order() int {
+	return 1
+}
+
+// Step calculates new position of a particle using backward Euler method
+func (ImplicitEulerIntegrator) Step(p *Particle, dt float64) pixel.Vec {
+	// v_{t+1} = v_{t} + h*a(p_{t+1}, v_{t+1})
+	// p_{t+1} = p_{t} + h*v_{t+1}
+	return p.implicitStep(dt, 1)
+}
+
+// Name returns name of the integration method
+func (ImplicitMidpointIntegrator) Name() string {
+	return "Implicit Midpoint"
+}
+
+// Order returns order of accuracy of the integration method
+func (ImplicitMidpointIntegrator) Order() int {
+	return 2
+}
+
+// Step calculates new position of a particle using implicit midpoint method
+func (ImplicitMidpointIntegrator) Step(p *Particle, dt float64) pixel.Vec {
+	// v_{t+1} = v_{t} + h*a((p_{t} + p_{t+1})/2, (v_{t} + v_{t+1})/2)
+	// p_{t+1} = p_{t} + h*(v_{t} + v_{t+1})/2
+	return p.implicitStep(dt, 0.5)
+}
+
+// implicitStep solves implicit step of the θ-method for the speed at the end of the time-step
+// using Newton iterations. The force model is evaluated in the state
+// v_{θ} = (1-θ)*v_{t} + θ*v_{t+1}, p_{θ} = p_{t} + θ*h*v_{θ}
+// which gives backward Euler method for θ = 1 and implicit midpoint method for θ = 1/2
+func (p *Particle) implicitStep(dt float64, theta float64) pixel.Vec {
+	stage := func(speed pixel.Vec) (pixel.Vec, pixel.Vec) {
+		vTheta := p.Speed.Scaled(1 - theta).Add(speed.Scaled(theta))
+		return p.Position.Add(vTheta.Scaled(theta * dt * PixelsPerMeter)), vTheta
+	}
+
+	// initial guess is given by Explicit Euler method
+	speed := p.Speed.Add(p.acceleration(p.Position, p.Speed).Scaled(dt))
+
+	for i := 0; i < newtonMaxIterations; i++ {
+		position, vTheta := stage(speed)
+
+		// G(v) = v - v_{t} - h*a(p_{θ}, v_{θ})
+		residual := speed.Sub(p.Speed).Sub(p.acceleration(position, vTheta).Scaled(dt))
+
+		// dG/dv = I - h*θ*(θ*h*da/dp + da/dv)
+		dPosition, dSpeed := p.accelerationJacobian(position, vTheta)
+		jacobian := Identity2.Sub(
+			dPosition.Scaled(theta * dt * PixelsPerMeter).Add(dSpeed).Scaled(theta * dt))
+
+		delta, ok := jacobian.Solve(residual)
+		if !ok {
+			break
+		}
+		speed = speed.Sub(delta)
+
+		if delta.Len() < newtonTolerance {
+			break
+		}
+	}
+
+	_, vTheta := stage(speed)
+	p.Speed = speed
+
+	return p.Position.Add(vTheta.Scaled(dt * PixelsPerMeter))
+}
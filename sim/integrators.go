@@ -0,0 +1,198 @@
+package sim
+
+import "github.com/faiface/pixel"
+
+// Integrator represents a method for particle position integration. Step is called by several
+// goroutines at once for particles of a particle system
+type Integrator interface {
+	// Name returns human readable name of the integration method
+	Name() string
+	// Order returns the order of accuracy of the integration method
+	Order() int
+	// Step advances particle p by time-step dt in s, updates it's speed and returns it's new
+	// position in pixels
+	Step(p *Particle, dt float64) pixel.Vec
+}
+
+// integrators is the registry of position integration methods selectable in gui. Built-in
+// integrators are registered here, other integrators may be added from init functions using
+// RegisterIntegrator
+var integrators = []Integrator{
+	ExplicitEulerIntegrator{},
+	ExplicitMidpointIntegrator{},
+	VerletIntegrator{},
+	RK4Integrator{},
+}
+
+// RegisterIntegrator adds a new position integration method to the registry of integrators
+func RegisterIntegrator(integrator Integrator) {
+	integrators = append(integrators, integrator)
+}
+
+// Integrators returns all registered position integration methods in order of registration
+func Integrators() []Integrator {
+	return integrators
+}
+
+// ExplicitEulerIntegrator calculates new position of a particle based on it's previous position
+// and it's speed
+type ExplicitEulerIntegrator struct{}
+
+// Name returns name of the integration method
+func (ExplicitEulerIntegrator) Name() string {
+	return "Explicit Euler"
+}
+
+// Order returns order of accuracy of the integration method
+func (ExplicitEulerIntegrator) Order() int {
+	return 1
+}
+
+// Step calculates new position of a particle using Explicit Euler method
+func (ExplicitEulerIntegrator) Step(p *Particle, dt float64) pixel.Vec {
+	// v_{t+1} = v_{t} + h*(F/m)
+	p.Speed = p.Speed.Add(p.acceleration(p.Position, p.Speed).Scaled(dt))
+
+	// p_{t+1} = p_{t} + h*v(t)
+	return p.Position.Add(p.Speed.Scaled(dt).Scaled(PixelsPerMeter))
+}
+
+// ExplicitMidpointIntegrator calculates new position of a particle based on it's previous position
+// and it's speed
+type ExplicitMidpointIntegrator struct{}
+
+// Name returns name of the integration method
+func (ExplicitMidpointIntegrator) Name() string {
+	return "Explicit Midpoint"
+}
+
+// Order returns order of accuracy of the integration method
+func (ExplicitMidpointIntegrator) Order() int {
+	return 2
+}
+
+// Step calculates new position of a particle using Explicit Midpoint method
+func (ExplicitMidpointIntegrator) Step(p *Particle, dt float64) pixel.Vec {
+	// v_{t+(1/2)} = v_{t} + (h/2)*(F/m)
+	speed := p.Speed.Add(p.acceleration(p.Position, p.Speed).Scaled(dt / 2))
+	// p_{t+(1/2)} = p_{t} + (h/2)*v(t)
+	position := p.Position.Add(p.Speed.Scaled(dt / 2).Scaled(PixelsPerMeter))
+
+	// v_{t+1} = v_{t} + h*(F(p_{t+(1/2)}, v_{t+(1/2)})/m)
+	// p_{t+1} = p_{t} + h*v_{t+(1/2)}
+	p.Speed = p.Speed.Add(p.acceleration(position, speed).Scaled(dt))
+	return p.Position.Add(speed.Scaled(dt).Scaled(PixelsPerMeter))
+}
+
+// VerletIntegrator calculates new position of a particle based on Verlet Integration Scheme
+type VerletIntegrator struct{}
+
+// Name returns name of the integration method
+func (VerletIntegrator) Name() string {
+	return "Verlet"
+}
+
+// Order returns order of accuracy of the integration method
+func (VerletIntegrator) Order() int {
+	return 2
+}
+
+// Step calculates new position of a particle using Verlet Integration Scheme
+func (VerletIntegrator) Step(p *Particle, dt float64) pixel.Vec {
+	// While calculating next position using Verlet Integration scheme with changing time-step (Δt)
+	// variable, Verlet scheme does not approximate the solution to the differencial equation.
+	// This can be corrected using the following formula, where iteration rule becomes:
+	// p_{t+1} = p_{t} + (p_{t} - p_{t-1}) * h_{i} / h_{i-1} + a * ((h_{i} + h_{i-1}) * h_{i}) / 2
+	acceleration := p.acceleration(p.nextPosition, p.Speed)
+	pNext := p.nextPosition.Add(
+		p.nextPosition.Sub(p.Position).Scaled(dt / p.prevDt)).Add(
+		acceleration.Scaled(PixelsPerMeter).Scaled((dt + p.prevDt) * dt / 2))
+
+	// speed at p_{t} is approximated by central difference
+	// v_{t} = (p_{t+1} - p_{t-1}) / (h_{i} + h_{i-1})
+	if dt+p.prevDt > 0 {
+		p.Speed = pNext.Sub(p.Position).Scaled(1 / ((dt + p.prevDt) * PixelsPerMeter))
+	}
+
+	tmp := p.nextPosition
+	p.prevDt = dt
+	p.nextPosition = pNext
+
+	return tmp
+}
+
+// RK4Integrator calculates new position of a particle based on classical fourth order Runge-Kutta
+// method, where the force model is evaluated at four stages of the time-step
+type RK4Integrator struct{}
+
+// Name returns name of the integration method
+func (RK4Integrator) Name() string {
+	return "RK4"
+}
+
+// Order returns order of accuracy of the integration method
+func (RK4Integrator) Order() int {
+	return 4
+}
+
+// Step calculates new position of a particle using classical Runge-Kutta 4 method
+func (RK4Integrator) Step(p *Particle, dt float64) pixel.Vec {
+	// k1 = f(t, y_{t})
+	// k2 = f(t + h/2, y_{t} + (h/2)*k1)
+	// k3 = f(t + h/2, y_{t} + (h/2)*k2)
+	// k4 = f(t + h, y_{t} + h*k3)
+	// y_{t+1} = y_{t} + (h/6)*(k1 + 2*k2 + 2*k3 + k4)
+	// where y = (p, v) and f(t, y) = (v, F/m)
+	k1x := p.Speed.Scaled(PixelsPerMeter)
+	k1v := p.acceleration(p.Position, p.Speed)
+
+	x2 := p.Position.Add(k1x.Scaled(dt / 2))
+	v2 := p.Speed.Add(k1v.Scaled(dt / 2))
+	k2x := v2.Scaled(PixelsPerMeter)
+	k2v := p.acceleration(x2, v2)
+
+	x3 := p.Position.Add(k2x.Scaled(dt / 2))
+	v3 := p.Speed.Add(k2v.Scaled(dt / 2))
+	k3x := v3.Scaled(PixelsPerMeter)
+	k3v := p.acceleration(x3, v3)
+
+	x4 := p.Position.Add(k3x.Scaled(dt))
+	v4 := p.Speed.Add(k3v.Scaled(dt))
+	k4x := v4.Scaled(PixelsPerMeter)
+	k4v := p.acceleration(x4, v4)
+
+	p.Speed = p.Speed.Add(k1v.Add(k2v.Scaled(2)).Add(k3v.Scaled(2)).Add(k4v).Scaled(dt / 6))
+
+	return p.Position.Add(k1x.Add(k2x.Scaled(2)).Add(k3x.Scaled(2)).Add(k4x).Scaled(dt / 6))
+}
+
+// acceleration evaluates the force field acting on a particle in a given state and returns the
+// resulting accelleration in m*s^{-2}. Position is in pixels and speed in m*s^{-1}
+func (p *Particle) acceleration(position pixel.Vec, speed pixel.Vec) pixel.Vec {
+	return p.forceField().Force(p, position, speed).Scaled(1 / p.Mass)
+}
+
+// accelerationJacobian returns partial derivatives of the accelleration with respect to position
+// in m*s^{-2}*px^{-1} and with respect to speed in s^{-1}
+func (p *Particle) accelerationJacobian(position pixel.Vec, speed pixel.Vec) (Matrix2, Matrix2) {
+	dPosition, dSpeed := p.forceField().Jacobian(p, position, speed)
+	return dPosition.Scaled(1 / p.Mass), dSpeed.Scaled(1 / p.Mass)
+}
+
+// forceField returns force field acting on the particle
+func (p *Particle) forceField() ForceField {
+	if p.Forces == nil {
+		return DefaultForces
+	}
+	return *p.Forces
+}
+
+// resetHistory rebuilds history of positions kept by Verlet integrator after the state of the
+// particle was changed outside of the integrator, e.g. by a collision. It is rebuilt whichever
+// integrator is used, so the history is valid when Verlet integrator is chosen later
+func (p *Particle) resetHistory(dt float64) {
+	acceleration := p.acceleration(p.Position, p.Speed)
+	p.prevDt = dt
+	p.nextPosition = p.Position.Add(p.Speed.Scaled(PixelsPerMeter).Scaled(dt)).Add(
+		acceleration.Scaled(PixelsPerMeter).Scaled(dt * dt * 0.5))
+}
@@ -0,0 +1,548 @@
+package sim
+
+import (
+	"math"
+	"testing"
+
+	"github.com/faiface/pixel"
+)
+
+func createParticle(
+	pos pixel.Vec,
+	nextPos pixel.Vec,
+	speed pixel.Vec,
+	prevDT float64,
+	lifespan float64) Particle {
+	p := Particle{
+		Position:     pos,
+		nextPosition: nextPos,
+		Speed:        speed,
+		prevDt:       prevDT,
+		Lifespan:     lifespan,
+		Alive:        0.0,
+		Mass:         1.0,
+		Radius:       ParticleRadius,
+	}
+
+	return p
+}
+
+// TestP01 tests particle initialization
+func TestP01(t *testing.T) {
+	var (
+		pos           = pixel.V(0, 0)
+		nextPos       = pixel.V(0, 0)
+		speed         = pixel.V(0, 10)
+		prevDt        = 0.0
+		lifespan      = 10.0
+		ePosition     = pixel.V(0, 0)
+		eNextPosition = pixel.V(0, 0)
+		eSpeed        = pixel.V(0, 10)
+		eLifespan     = 10.0
+	)
+
+	p := createParticle(pos, nextPos, speed, prevDt, lifespan)
+
+	if p.Position != ePosition {
+		t.Errorf("Particle initialization: Expected position of %f got %f", ePosition, p.Position)
+	}
+
+	if p.nextPosition != eNextPosition {
+		t.Errorf(
+			"Particle initialization: Expected next position of %f got %f",
+			eNextPosition, p.nextPosition,
+		)
+	}
+
+	if p.Speed != eSpeed {
+		t.Errorf("Particle initialization: Expected speed of %f got %f", eSpeed, p.Speed)
+	}
+
+	if p.Lifespan != eLifespan {
+		t.Errorf("Particle initialization: Expected lifespan of %f got %f", eLifespan, p.Lifespan)
+	}
+}
+
+// TestIee test Explicit euler position integration method
+func TestIee(t *testing.T) {
+	var (
+		pos       = pixel.V(0, 0)
+		nextPos   = pixel.V(0, 0)
+		speed     = pixel.V(0, 10)
+		prevDT    = 0.
+		lifespan  = 10.0
+		eSpeed    = pixel.V(0, 0.1899999999999995)
+		ePosition = pixel.V(0, 18.99999999999995)
+	)
+
+	p := createParticle(pos, nextPos, speed, prevDT, lifespan)
+
+	p.Position = ExplicitEulerIntegrator{}.Step(&p, 1)
+
+	if p.Speed.X != eSpeed.X || p.Speed.Y != eSpeed.Y {
+		t.Errorf("Explicit Euler Integrator DT=1: Expected speed of %f got %f", eSpeed, p.Speed)
+	}
+
+	if p.Position.X != ePosition.X || p.Position.Y != ePosition.Y {
+		t.Errorf(
+			"Explicit Euler Integrator DT=1: Expected position of %f got %f", ePosition, p.Position,
+		)
+	}
+
+	p.Position = ExplicitEulerIntegrator{}.Step(&p, 0)
+
+	if p.Speed.X != eSpeed.X || p.Speed.Y != eSpeed.Y {
+		t.Errorf("Explicit Euler Integrator DT=0: Expected speed of %f got %f", eSpeed, p.Speed)
+	}
+
+	if p.Position.X != ePosition.X || p.Position.Y != ePosition.Y {
+		t.Errorf(
+			"Explicit Euler Integrator DT=0: Expected position of %f got %f", ePosition, p.Position,
+		)
+	}
+}
+
+// TestIv tests Verlet position integration method
+func TestIv(t *testing.T) {
+	var (
+		pos            = pixel.V(0, 0)
+		nextPos        = pixel.V(0, 1)
+		speed          = pixel.V(0, 0)
+		prevDT         = 1.0
+		lifespan       = 10.0
+		ePosition1     = pixel.V(0, 1)
+		eNextPosition1 = pixel.V(0, -979)
+		ePosition2     = pixel.V(0, -979)
+		eNextPosition2 = pixel.V(0, -2940)
+	)
+
+	p := createParticle(pos, nextPos, speed, prevDT, lifespan)
+
+	p.Position = VerletIntegrator{}.Step(&p, 1)
+
+	if p.Position.X != ePosition1.X || p.Position.Y != ePosition1.Y {
+		t.Errorf("Verlet Integrator DT=1: Expected position of %f got %f", ePosition1, p.Position)
+	}
+
+	if p.nextPosition.X != eNextPosition1.X || p.nextPosition.Y != eNextPosition1.Y {
+		t.Errorf(
+			"Verlet Integrator DT=1: Expected next position of %f got %f",
+			eNextPosition1, p.nextPosition,
+		)
+	}
+
+	p.Position = VerletIntegrator{}.Step(&p, 1)
+
+	if p.Position.X != ePosition2.X || p.Position.Y != ePosition2.Y {
+		t.Errorf("Verlet Integrator DT=1: Expected position of %f got %f", ePosition1, p.Position)
+	}
+
+	if p.nextPosition.X != eNextPosition2.X || p.nextPosition.Y != eNextPosition2.Y {
+		t.Errorf(
+			"Verlet Integrator DT=1: Expected next position of %f got %f",
+			eNextPosition1, p.nextPosition,
+		)
+	}
+}
+
+// TestImidpoint tests Explicit Midpoint position integration method is exact for the quadratic
+// trajectory of a projectile under constant gravity, p_{t+1} = p_{t} + h*v_{t} + (h^2/2)*g
+func TestImidpoint(t *testing.T) {
+	var (
+		pos      = pixel.V(0, 0)
+		speed    = pixel.V(3, 10)
+		lifespan = 10.0
+		duration = 2.0
+	)
+
+	for _, steps := range []int{1, 4, 32} {
+		dt := duration / float64(steps)
+		ePosition := projectilePosition(pos, speed, duration)
+		eSpeed := speed.Add(Gravity.Scaled(duration))
+
+		p := createParticle(pos, pos, speed, dt, lifespan)
+		for i := 0; i < steps; i++ {
+			p.Position = ExplicitMidpointIntegrator{}.Step(&p, dt)
+		}
+
+		if err := p.Position.To(ePosition).Len(); err > 1e-9 {
+			t.Errorf("Explicit Midpoint Integrator steps=%d: Expected position of %f got %f",
+				steps, ePosition, p.Position)
+		}
+
+		if err := p.Speed.To(eSpeed).Len(); err > 1e-12 {
+			t.Errorf("Explicit Midpoint Integrator steps=%d: Expected speed of %f got %f", steps,
+				eSpeed, p.Speed)
+		}
+	}
+}
+
+// projectilePosition returns analytic position of a particle in pixels launched from pos with
+// speed in m*s^{-1} after time t
+func projectilePosition(pos pixel.Vec, speed pixel.Vec, t float64) pixel.Vec {
+	return pos.Add(speed.Scaled(t).Add(Gravity.Scaled(t * t / 2)).Scaled(PixelsPerMeter))
+}
+
+// stokesProjectilePosition returns analytic position of a particle in pixels launched from pos
+// with speed in m*s^{-1} after time t under gravity and Stokes drag with time constant tau in s,
+// x(t) = x_0 + v_T*t + (v_0 - v_T)*τ*(1 - e^{-t/τ}) where v_T = g*τ
+func stokesProjectilePosition(pos pixel.Vec, speed pixel.Vec, tau float64, t float64) pixel.Vec {
+	terminal := Gravity.Scaled(tau)
+	return pos.Add(terminal.Scaled(t).Add(
+		speed.Sub(terminal).Scaled(tau * (1 - math.Exp(-t/tau)))).Scaled(PixelsPerMeter))
+}
+
+// TestIrk4 tests Runge-Kutta 4 position integration method against analytic projectile solutions
+func TestIrk4(t *testing.T) {
+	var (
+		pos       = pixel.V(0, 0)
+		speed     = pixel.V(3, 10)
+		lifespan  = 10.0
+		duration  = 2.0
+		stokes    = StokesDrag
+		viscosity = Parameter{Value: 0.5}
+		forces    = ForceField{
+			UniformGravity{Acceleration: Gravity},
+			Drag{Mode: &stokes, Viscosity: &viscosity},
+		}
+	)
+
+	// The projectile trajectory is a polynomial of second degree which lies within the fourth
+	// order convergence of Runge-Kutta 4 so the error must stay on the level of round-off for any
+	// time-step
+	for _, steps := range []int{8, 16, 32, 64} {
+		dt := duration / float64(steps)
+		ePosition := projectilePosition(pos, speed, duration)
+		eSpeed := speed.Add(Gravity.Scaled(duration))
+
+		p := createParticle(pos, pos, speed, dt, lifespan)
+		for i := 0; i < steps; i++ {
+			p.Position = RK4Integrator{}.Step(&p, dt)
+		}
+
+		if err := p.Position.To(ePosition).Len(); err > 1e-9 {
+			t.Errorf(
+				"RK4 Integrator steps=%d: Expected position of %f got %f", steps, ePosition,
+				p.Position,
+			)
+		}
+
+		if err := p.Speed.To(eSpeed).Len(); err > 1e-12 {
+			t.Errorf("RK4 Integrator steps=%d: Expected speed of %f got %f", steps, eSpeed, p.Speed)
+		}
+	}
+
+	// Stokes drag makes the trajectory exponential, so the error of the fourth order Runge-Kutta 4
+	// drops sixteen times when the time-step is halved, while the first order Explicit Euler error
+	// halves
+	var prevErr, prevEulerErr float64
+	for _, steps := range []int{32, 64, 128, 256} {
+		dt := duration / float64(steps)
+		p := createParticle(pos, pos, speed, dt, lifespan)
+		p.Forces = &forces
+		p.Mass = 0.05
+		e := p
+
+		tau := p.Mass / (6 * math.Pi * viscosity.Value * p.Radius)
+		ePosition := stokesProjectilePosition(pos, speed, tau, duration)
+		for i := 0; i < steps; i++ {
+			p.Position = RK4Integrator{}.Step(&p, dt)
+			e.Position = ExplicitEulerIntegrator{}.Step(&e, dt)
+		}
+
+		err := p.Position.To(ePosition).Len()
+		if prevErr != 0 && math.Abs(prevErr/err-16) > 1.5 {
+			t.Errorf(
+				"RK4 Integrator Stokes drag steps=%d: Expected error ratio of 16 got %f", steps,
+				prevErr/err,
+			)
+		}
+		prevErr = err
+
+		eulerErr := e.Position.To(ePosition).Len()
+		if prevEulerErr != 0 && math.Abs(prevEulerErr/eulerErr-2) > 0.2 {
+			t.Errorf(
+				"Explicit Euler Integrator Stokes drag steps=%d: Expected error ratio of 2 got %f",
+				steps, prevEulerErr/eulerErr,
+			)
+		}
+		prevEulerErr = eulerErr
+	}
+}
+
+// TestIrk45 tests adaptive Dormand-Prince position integration method against analytic projectile
+// solutions
+func TestIrk45(t *testing.T) {
+	var (
+		pos       = pixel.V(0, 0)
+		speed     = pixel.V(3, 10)
+		lifespan  = 10.0
+		dt        = 1.5
+		ePosition = projectilePosition(pos, speed, dt)
+		eSpeed    = speed.Add(Gravity.Scaled(dt))
+	)
+
+	integrator := &DormandPrinceIntegrator{absTolerance: 1e-6, relTolerance: 1e-6}
+	p := createParticle(pos, pos, speed, dt, lifespan)
+
+	p.Position = integrator.Step(&p, dt)
+
+	if err := p.Position.To(ePosition).Len(); err > 1e-9 {
+		t.Errorf("RK45 Integrator DT=%f: Expected position of %f got %f", dt, ePosition, p.Position)
+	}
+
+	if err := p.Speed.To(eSpeed).Len(); err > 1e-12 {
+		t.Errorf("RK45 Integrator DT=%f: Expected speed of %f got %f", dt, eSpeed, p.Speed)
+	}
+
+	if accepted, rejected := integrator.StepCount(); accepted < 1 || rejected != 0 {
+		t.Errorf(
+			"RK45 Integrator DT=%f: Expected at least 1 accepted and no rejected steps got %d and %d",
+			dt, accepted, rejected,
+		)
+	}
+
+	if accepted, rejected := integrator.StepCount(); accepted != 0 || rejected != 0 {
+		t.Errorf(
+			"RK45 Integrator: Expected step count reset got %d accepted and %d rejected",
+			accepted, rejected,
+		)
+	}
+
+	// Stokes drag decays the speed exponentially, so the first substep as long as the whole
+	// time-step is rejected and substeps are chosen by the tolerance. Error of the position in m
+	// stays within the tolerance and tighter tolerance takes more substeps
+	stokes := StokesDrag
+	forces := ForceField{
+		UniformGravity{Acceleration: Gravity},
+		Drag{Mode: &stokes, Viscosity: &Parameter{Value: 0.5}},
+	}
+	prevAccepted := 0
+	for _, tolerance := range []float64{1e-3, 1e-5, 1e-7} {
+		integrator := &DormandPrinceIntegrator{absTolerance: tolerance, relTolerance: tolerance}
+		p := createParticle(pos, pos, speed, dt, lifespan)
+		p.Forces = &forces
+		p.Mass = 0.05
+		tau := p.Mass / (6 * math.Pi * 0.5 * p.Radius)
+		ePosition := stokesProjectilePosition(pos, speed, tau, dt)
+
+		p.Position = integrator.Step(&p, dt)
+		accepted, rejected := integrator.StepCount()
+
+		if err := p.Position.To(ePosition).Len() / PixelsPerMeter; err > tolerance {
+			t.Errorf("RK45 Integrator Stokes drag tolerance=%g: Expected position of %f got %f",
+				tolerance, ePosition, p.Position)
+		}
+
+		if rejected == 0 {
+			t.Errorf("RK45 Integrator Stokes drag tolerance=%g: Expected rejected steps got none",
+				tolerance)
+		}
+
+		if accepted <= prevAccepted {
+			t.Errorf(
+				"RK45 Integrator Stokes drag tolerance=%g: Expected more than %d accepted steps got %d",
+				tolerance, prevAccepted, accepted,
+			)
+		}
+		prevAccepted = accepted
+	}
+}
+
+// stiffSpringDistance integrates particle attached to a stiff spring and returns the largest
+// distance from the anchor in pixels reached during the simulation
+func stiffSpringDistance(integrator Integrator, dt float64, steps int) float64 {
+	var (
+		pos      = pixel.V(10, 0)
+		speed    = pixel.V(0, 0)
+		lifespan = 10.0
+		forces   = ForceField{UniformGravity{Acceleration: Gravity}, Spring{
+			Anchor:     pixel.V(0, 0),
+			RestLength: 0,
+			Stiffness:  1e4,
+			Damping:    0,
+		}}
+	)
+
+	p := createParticle(pos, pos, speed, dt, lifespan)
+	p.Forces = &forces
+
+	distance := 0.0
+	for i := 0; i < steps; i++ {
+		p.Position = integrator.Step(&p, dt)
+		distance = math.Max(distance, p.Position.Len())
+	}
+	return distance
+}
+
+// TestIimplicit tests stability of implicit position integration methods on a stiff spring where
+// Explicit Euler method diverges
+func TestIimplicit(t *testing.T) {
+	const (
+		// spring with stiffness 10^4 N/m has angular frequency 100 rad/s, so h*ω = 3 which is
+		// beyond the stability limit h*ω < 2 of Explicit Euler method
+		dt    = 0.03
+		steps = 500
+		// initial displacement is 10 pixels and the equilibrium is moved by gravity only by
+		// m*g/k ≈ 0.1 pixels
+		eMaxDistance = 10.5
+	)
+
+	if distance := stiffSpringDistance(ExplicitEulerIntegrator{}, dt, steps); distance < 1e6 {
+		t.Errorf(
+			"Explicit Euler Integrator stiff spring: Expected divergence got distance of %f",
+			distance,
+		)
+	}
+
+	for _, integrator := range []Integrator{ImplicitEulerIntegrator{}, ImplicitMidpointIntegrator{}} {
+		if distance := stiffSpringDistance(integrator, dt, steps); distance > eMaxDistance {
+			t.Errorf(
+				"%s Integrator stiff spring: Expected distance at most %f got %f",
+				integrator.Name(), eMaxDistance, distance,
+			)
+		}
+	}
+}
+
+// springEnergy returns total energy in J of a particle of unit mass attached to a spring with
+// anchor in the origin including the potential energy of gravity
+func springEnergy(p Particle, stiffness float64) float64 {
+	d := p.Position.Scaled(1 / PixelsPerMeter)
+	return p.Speed.Dot(p.Speed)/2 + stiffness*d.Dot(d)/2 - Gravity.Dot(d)
+}
+
+// TestIsymplectic tests long-term energy behaviour of symplectic position integration methods on
+// a spring oscillator. Energy error of symplectic methods oscillates but it does not grow, so the
+// largest error reached during the first tenth of the simulation is not exceeded later
+func TestIsymplectic(t *testing.T) {
+	const (
+		// spring with stiffness 100 N/m has angular frequency 10 rad/s, so h*ω = 0.1 and the
+		// simulation lasts roughly 160 periods
+		stiffness = 100.0
+		dt        = 0.01
+		steps     = 10000
+		eMaxDrift = 0.15
+	)
+
+	energyDrift := func(integrator Integrator) (float64, float64) {
+		forces := ForceField{UniformGravity{Acceleration: Gravity}, Spring{Stiffness: stiffness}}
+		p := createParticle(pixel.V(10, 0), pixel.V(10, 0), pixel.V(0, 0), dt, 1000)
+		p.Forces = &forces
+
+		e0 := springEnergy(p, stiffness)
+		early, drift := 0.0, 0.0
+		for i := 0; i < steps; i++ {
+			p.Position = integrator.Step(&p, dt)
+			drift = math.Max(drift, math.Abs(springEnergy(p, stiffness)-e0)/e0)
+			if i < steps/10 {
+				early = drift
+			}
+		}
+		return early, drift
+	}
+
+	for _, integrator := range []Integrator{
+		SymplecticEulerIntegrator{},
+		VelocityVerletIntegrator{},
+		LeapfrogIntegrator{},
+		YoshidaIntegrator{},
+	} {
+		early, drift := energyDrift(integrator)
+		if drift > eMaxDrift || drift > early*1.01 {
+			t.Errorf(
+				"%s Integrator spring: Expected bounded relative energy drift of %f got %f",
+				integrator.Name(), early, drift,
+			)
+		}
+	}
+
+	// non-symplectic method of the same order as velocity Verlet gains energy steadily
+	if early, drift := energyDrift(ExplicitMidpointIntegrator{}); drift < early*2 {
+		t.Errorf(
+			"Explicit Midpoint Integrator spring: Expected growing energy drift over %f got %f",
+			early*2, drift,
+		)
+	}
+}
+
+// TestIdrag tests terminal velocities of particles of various masses and radii falling with Stokes
+// and Newton drag against analytic values
+func TestIdrag(t *testing.T) {
+	var (
+		pos       = pixel.V(0, 0)
+		speed     = pixel.V(3, 10)
+		viscosity = Parameter{Value: 0.5}
+		density   = Parameter{Value: 10}
+	)
+
+	for _, body := range []struct{ mass, radius float64 }{
+		{0.05, ParticleRadius},
+		{0.2, ParticleRadius},
+		{0.05, 2 * ParticleRadius},
+	} {
+		for _, mode := range []DragMode{StokesDrag, NewtonDrag} {
+			mode := mode
+			forces := ForceField{
+				UniformGravity{Acceleration: Gravity},
+				Drag{Mode: &mode, Viscosity: &viscosity, Density: &density},
+			}
+			p := createParticle(pos, pos, speed, 0, 100)
+			p.Forces = &forces
+			p.Mass = body.mass
+			p.Radius = body.radius
+			p.DragCoefficient = 0.47
+
+			// |v_T| = m*g / (6*π*μ*r) with Stokes drag, the heavier or the smaller the faster
+			name := "Stokes"
+			eTerminal := p.Mass * Gravity.Len() / (6 * math.Pi * viscosity.Value * p.Radius)
+			if mode == NewtonDrag {
+				// |v_T| = sqrt(m*g / ((1/2)*ρ*C_d*A))
+				name = "Newton"
+				eTerminal = math.Sqrt(p.Mass * Gravity.Len() /
+					(0.5 * density.Value * p.DragCoefficient * math.Pi * p.Radius * p.Radius))
+			}
+
+			for i := 0; i < 6000; i++ {
+				p.Position = RK4Integrator{}.Step(&p, 0.01)
+			}
+
+			if math.Abs(p.Speed.Len()-eTerminal) > 1e-6*eTerminal {
+				t.Errorf(
+					"RK4 Integrator %s drag m=%.2f r=%.3f: Expected terminal velocity of %f got %f",
+					name, body.mass, body.radius, eTerminal, p.Speed.Len(),
+				)
+			}
+		}
+	}
+}
+
+// TestIswitch tests history of positions is rebuilt when another integrator is chosen, so the
+// particle continues its projectile trajectory
+func TestIswitch(t *testing.T) {
+	const dt = 0.01
+	var (
+		pos   = pixel.V(0, 0)
+		speed = pixel.V(2, 5)
+	)
+
+	particleSystem := ParticleSystem{}
+	particleSystem.AddForce(UniformGravity{Acceleration: Gravity})
+	particles := particleSystem.Particles()
+	particles.Add(createParticle(pos, pos, speed, dt, 10))
+
+	steps := 0
+	for _, integrator := range []Integrator{RK4Integrator{}, VerletIntegrator{}, RK4Integrator{}} {
+		particleSystem.SetIntegrator(integrator, dt)
+		for i := 0; i < 50; i++ {
+			integrate(particles, integrator, dt)
+			steps++
+		}
+
+		ePosition := projectilePosition(pos, speed, float64(steps)*dt)
+		if diff := particles.Position(0).To(ePosition).Len(); diff > 1e-6 {
+			t.Errorf("Switch to %s: Expected position of %f got %f", integrator.Name(), ePosition,
+				particles.Position(0))
+		}
+	}
+}
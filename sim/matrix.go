@@ -0,0 +1,49 @@
+package sim
+
+import "github.com/faiface/pixel"
+
+// Matrix2 represents 2x2 matrix used for derivatives of vector quantities
+type Matrix2 struct {
+	XX, XY float64
+	YX, YY float64
+}
+
+// Identity2 is 2x2 identity matrix
+var Identity2 = Matrix2{XX: 1, YY: 1}
+
+// Outer2 returns outer product u*v^T of two vectors
+func Outer2(u pixel.Vec, v pixel.Vec) Matrix2 {
+	return Matrix2{
+		XX: u.X * v.X, XY: u.X * v.Y,
+		YX: u.Y * v.X, YY: u.Y * v.Y,
+	}
+}
+
+// Add returns sum of matrices m and n
+func (m Matrix2) Add(n Matrix2) Matrix2 {
+	return Matrix2{m.XX + n.XX, m.XY + n.XY, m.YX + n.YX, m.YY + n.YY}
+}
+
+// Sub returns difference of matrices m and n
+func (m Matrix2) Sub(n Matrix2) Matrix2 {
+	return Matrix2{m.XX - n.XX, m.XY - n.XY, m.YX - n.YX, m.YY - n.YY}
+}
+
+// Scaled returns matrix m multiplied by c
+func (m Matrix2) Scaled(c float64) Matrix2 {
+	return Matrix2{m.XX * c, m.XY * c, m.YX * c, m.YY * c}
+}
+
+// Apply returns product of matrix m and vector u
+func (m Matrix2) Apply(u pixel.Vec) pixel.Vec {
+	return pixel.V(m.XX*u.X+m.XY*u.Y, m.YX*u.X+m.YY*u.Y)
+}
+
+// Solve returns vector x satisfying m*x = u. When m is singular, false is returned
+func (m Matrix2) Solve(u pixel.Vec) (pixel.Vec, bool) {
+	det := m.XX*m.YY - m.XY*m.YX
+	if det == 0 {
+		return pixel.ZV, false
+	}
+	return pixel.V((m.YY*u.X-m.XY*u.Y)/det, (m.XX*u.Y-m.YX*u.X)/det), true
+}
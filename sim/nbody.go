@@ -0,0 +1,427 @@
+package sim
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/faiface/pixel"
+)
+
+// quadTreeMaxDepth limits subdivision of the quadtree, particles closer than the smallest cell
+// share it
+const quadTreeMaxDepth = 32
+
+// quadNode represents square cell of a quadtree with the total mass and the centre of mass of
+// particles inside
+type quadNode struct {
+	center     pixel.Vec // in pixels
+	halfSize   float64   // in pixels
+	mass       float64   // in kg
+	massCenter pixel.Vec // in pixels
+	particle   *Particle // the only particle of a leaf, nil for cells with more particles
+	count      int       // number of particles inside
+	children   [4]int    // indices of child cells, zero when the cell is not subdivided
+	leaf       bool
+}
+
+// NBodyGravity represents Newtonian gravity between all particles of a system. Forces are
+// approximated by Barnes–Hut algorithm, distant cells of the quadtree act as a single body when
+// their size divided by the distance is smaller than theta
+type NBodyGravity struct {
+	constant  float64    // gravitational constant in m^3*kg^{-1}*s^{-2}
+	theta     *Parameter // opening angle of the cells, zero gives exact forces
+	softening *Parameter // in m, removes singularity of close encounters
+	nodes     []quadNode
+	replay    *stageReplay // fields of the particle being stepped, nil when the tree is used
+}
+
+// stageField represents gravitational field evaluated at one stage of a time-step
+type stageField struct {
+	position     pixel.Vec // in pixels
+	acceleration pixel.Vec // in m*s^{-2}
+	jacobian     Matrix2   // in s^{-2}
+}
+
+// stageReplay represents fields of the stages of one particle recorded in previous passes over the
+// time-step. The first stage without a field is left pending until all particles reach it
+type stageReplay struct {
+	stages   []stageField
+	next     int       // index of the next evaluation
+	pending  bool      // whether the step reached a stage without a field
+	position pixel.Vec // in pixels, of the pending stage
+}
+
+// field returns the recorded field of the next stage at position in pixels. Evaluations past the
+// pending stage return zero field, the rest of such step is discarded
+func (replay *stageReplay) field(position pixel.Vec, jacobian bool) (pixel.Vec, Matrix2) {
+	// implicit integrators evaluate the Jacobian in the state of the preceding force
+	if jacobian && replay.next > 0 {
+		if previous := replay.next - 1; previous < len(replay.stages) &&
+			replay.stages[previous].position == position {
+			return replay.stages[previous].acceleration, replay.stages[previous].jacobian
+		}
+		if replay.pending && replay.position == position {
+			return pixel.ZV, Matrix2{}
+		}
+	}
+
+	stage := replay.next
+	replay.next++
+	if stage < len(replay.stages) {
+		return replay.stages[stage].acceleration, replay.stages[stage].jacobian
+	}
+	if !replay.pending {
+		replay.pending = true
+		replay.position = position
+	}
+	return pixel.ZV, Matrix2{}
+}
+
+// Build builds the quadtree of particles. It has to be called whenever particles move
+func (gravity *NBodyGravity) Build(particles []Particle) {
+	gravity.nodes = gravity.nodes[:0]
+	if len(particles) == 0 {
+		return
+	}
+
+	min, max := particles[0].Position, particles[0].Position
+	for i := range particles {
+		position := particles[i].Position
+		min = pixel.V(math.Min(min.X, position.X), math.Min(min.Y, position.Y))
+		max = pixel.V(math.Max(max.X, position.X), math.Max(max.Y, position.Y))
+	}
+
+	gravity.nodes = append(gravity.nodes, quadNode{
+		center:   min.Add(max).Scaled(0.5),
+		halfSize: math.Max(max.X-min.X, max.Y-min.Y)/2 + 1,
+		leaf:     true,
+	})
+	for i := range particles {
+		gravity.insert(0, &particles[i], 0)
+	}
+}
+
+// insert adds particle p to the cell with index n
+func (gravity *NBodyGravity) insert(n int, p *Particle, depth int) {
+	node := &gravity.nodes[n]
+	mass := node.mass + p.Mass
+	if mass > 0 {
+		node.massCenter = node.massCenter.Scaled(node.mass / mass).Add(
+			p.Position.Scaled(p.Mass / mass))
+	}
+	node.mass = mass
+	node.count++
+
+	if node.leaf && node.count == 1 {
+		node.particle = p
+		return
+	}
+	if node.leaf && depth >= quadTreeMaxDepth {
+		node.particle = nil
+		return
+	}
+
+	if node.leaf {
+		// the particle of the leaf is moved to a child
+		previous := node.particle
+		node.particle = nil
+		node.leaf = false
+		gravity.insertChild(n, previous, depth)
+	}
+	gravity.insertChild(n, p, depth)
+}
+
+// insertChild adds particle p to the child of cell n which contains it
+func (gravity *NBodyGravity) insertChild(n int, p *Particle, depth int) {
+	node := gravity.nodes[n]
+	quadrant := 0
+	offset := pixel.V(-node.halfSize/2, -node.halfSize/2)
+	if p.Position.X >= node.center.X {
+		quadrant |= 1
+		offset.X = -offset.X
+	}
+	if p.Position.Y >= node.center.Y {
+		quadrant |= 2
+		offset.Y = -offset.Y
+	}
+
+	child := node.children[quadrant]
+	if child == 0 {
+		child = len(gravity.nodes)
+		gravity.nodes = append(gravity.nodes, quadNode{
+			center:   node.center.Add(offset),
+			halfSize: node.halfSize / 2,
+			leaf:     true,
+		})
+		gravity.nodes[n].children[quadrant] = child
+	}
+	gravity.insert(child, p, depth+1)
+}
+
+// field returns gravitational acceleration in m*s^{-2}, it's derivative with respect to position
+// in s^{-2} and gravitational potential in m^2*s^{-2} acting on particle p at position in pixels
+func (gravity *NBodyGravity) field(p *Particle, position pixel.Vec) (pixel.Vec, Matrix2, float64) {
+	acceleration, jacobian, potential := pixel.ZV, Matrix2{}, 0.0
+	if len(gravity.nodes) == 0 {
+		return acceleration, jacobian, potential
+	}
+
+	theta, epsilon := gravity.theta.Value, gravity.softening.Value
+	// cells waiting for visit, the stack is deep at most three cells per level of the tree
+	var buffer [4 * quadTreeMaxDepth]int
+	stack := append(buffer[:0], 0)
+	for len(stack) > 0 {
+		node := &gravity.nodes[stack[len(stack)-1]]
+		stack = stack[:len(stack)-1]
+		if node.mass == 0 || node.particle == p {
+			continue
+		}
+
+		d := node.massCenter.Sub(position).Scaled(1 / PixelsPerMeter)
+		r := d.Len()
+		if !node.leaf && 2*node.halfSize/PixelsPerMeter >= theta*r {
+			for _, child := range node.children {
+				if child != 0 {
+					stack = append(stack, child)
+				}
+			}
+			continue
+		}
+
+		// a = G*M*d/s^3, da/dx = G*M*(3*d*d^T/s^5 - I/s^3), φ = -G*M/s where s^2 = r^2 + ε^2
+		s := math.Sqrt(r*r + epsilon*epsilon)
+		if s == 0 {
+			continue
+		}
+		gm := gravity.constant * node.mass
+		acceleration = acceleration.Add(d.Scaled(gm / (s * s * s)))
+		jacobian = jacobian.Add(Outer2(d, d).Scaled(3 * gm / math.Pow(s, 5)).Sub(
+			Identity2.Scaled(gm / (s * s * s))))
+		potential -= gm / s
+	}
+
+	return acceleration, jacobian, potential
+}
+
+// Force returns gravitational force of all other particles in N
+func (gravity *NBodyGravity) Force(p *Particle, position pixel.Vec, speed pixel.Vec) pixel.Vec {
+	if gravity.replay != nil {
+		acceleration, _ := gravity.replay.field(position, false)
+		return acceleration.Scaled(p.Mass)
+	}
+	acceleration, _, _ := gravity.field(p, position)
+	return acceleration.Scaled(p.Mass)
+}
+
+// Jacobian returns partial derivatives of the gravitational force of all other particles
+func (gravity *NBodyGravity) Jacobian(
+	p *Particle,
+	position pixel.Vec,
+	speed pixel.Vec) (Matrix2, Matrix2) {
+	var jacobian Matrix2
+	if gravity.replay != nil {
+		_, jacobian = gravity.replay.field(position, true)
+	} else {
+		_, jacobian, _ = gravity.field(p, position)
+	}
+
+	// position in the derivative is measured in pixels
+	return jacobian.Scaled(p.Mass / PixelsPerMeter), Matrix2{}
+}
+
+// NBodySystem represents system of particles attracting each other by gravity. Total energy and
+// angular momentum are measured relative to the moment the integrator was chosen, so their drift
+// shows the error of the integrator
+type NBodySystem struct {
+	particles  []Particle
+	gravity    NBodyGravity
+	forces     ForceField
+	integrator Integrator
+	energy     float64 // in J, when the integrator was chosen
+	momentum   float64 // in kg*m^2*s^{-1}, when the integrator was chosen
+	positions  []pixel.Vec
+	states     []Particle    // particles at the beginning of the time-step
+	bodies     []Particle    // particles at the stage the quadtree is built for
+	replays    []stageReplay // recorded stages of every particle
+}
+
+// NewNBodySystem creates system of particles attracting each other with gravitational constant
+// in m^3*kg^{-1}*s^{-2}
+func NewNBodySystem(constant float64, theta *Parameter, softening *Parameter) *NBodySystem {
+	system := &NBodySystem{
+		gravity: NBodyGravity{constant: constant, theta: theta, softening: softening},
+	}
+	system.forces = ForceField{&system.gravity}
+	return system
+}
+
+// Particles returns particles of the system
+func (system *NBodySystem) Particles() []Particle {
+	return system.particles
+}
+
+// AddParticle adds a particle at position in pixels with speed in m*s^{-1} and mass in kg
+func (system *NBodySystem) AddParticle(
+	position pixel.Vec,
+	speed pixel.Vec,
+	mass float64) {
+	system.particles = append(system.particles, Particle{
+		Position:     position,
+		lastPosition: position,
+		Speed:        speed,
+		Mass:         mass,
+		Radius:       ParticleRadius,
+		Forces:       &system.forces,
+	})
+	system.integrator = nil
+}
+
+// Update advances all particles by time-step dt in s
+func (system *NBodySystem) Update(dt float64, positionIntegrator Integrator) {
+	system.gravity.Build(system.particles)
+
+	if system.integrator != positionIntegrator {
+		for i := range system.particles {
+			system.particles[i].resetHistory(dt)
+		}
+		system.integrator = positionIntegrator
+		system.energy, system.momentum = system.Energy(), system.AngularMomentum()
+	}
+
+	// Integrators step one particle at a time, so the step is replayed for all particles once per
+	// stage. Every pass evaluates forces of the recorded stages and stops at the first stage
+	// without a field, the quadtree is then rebuilt from positions of all particles at this stage.
+	// Particles whose step has no more stages take part with their new position
+	n := len(system.particles)
+	if cap(system.positions) < n {
+		system.positions = make([]pixel.Vec, n)
+		system.states = make([]Particle, n)
+		system.bodies = make([]Particle, n)
+		system.replays = make([]stageReplay, n)
+	}
+	positions, states := system.positions[:n], system.states[:n]
+	bodies, replays := system.bodies[:n], system.replays[:n]
+	copy(states, system.particles)
+	for i := range replays {
+		replays[i].stages = replays[i].stages[:0]
+	}
+
+	// substeps of adaptive integrators are counted only by the last pass
+	var accepted, rejected int
+	counter, counted := positionIntegrator.(*DormandPrinceIntegrator)
+	if counted {
+		accepted, rejected = counter.StepCount()
+	}
+
+	for {
+		pending := false
+		for i := range system.particles {
+			replay := &replays[i]
+			replay.next, replay.pending = 0, false
+			system.particles[i] = states[i]
+
+			system.gravity.replay = replay
+			positions[i] = positionIntegrator.Step(&system.particles[i], dt)
+			system.gravity.replay = nil
+
+			bodies[i] = states[i]
+			bodies[i].Position = positions[i]
+			if replay.pending {
+				bodies[i].Position = replay.position
+				pending = true
+			}
+		}
+		if !pending {
+			break
+		}
+
+		system.gravity.Build(bodies)
+		for i := range replays {
+			if replays[i].pending {
+				acceleration, jacobian, _ := system.gravity.field(&bodies[i], bodies[i].Position)
+				replays[i].stages = append(replays[i].stages, stageField{
+					position:     bodies[i].Position,
+					acceleration: acceleration,
+					jacobian:     jacobian,
+				})
+			}
+		}
+		if counted {
+			counter.StepCount()
+		}
+	}
+	if counted {
+		counter.addStepCount(accepted, rejected)
+	}
+
+	for i := range system.particles {
+		system.particles[i].lastPosition = system.particles[i].Position
+		system.particles[i].Position = positions[i]
+	}
+}
+
+// Energy returns total kinetic and potential energy of the system in J
+func (system *NBodySystem) Energy() float64 {
+	system.gravity.Build(system.particles)
+
+	energy := 0.0
+	for i := range system.particles {
+		p := &system.particles[i]
+		_, _, potential := system.gravity.field(p, p.Position)
+		// every pair is counted twice by potentials of both particles
+		energy += p.Mass*p.Speed.Dot(p.Speed)/2 + p.Mass*potential/2
+	}
+	return energy
+}
+
+// AngularMomentum returns total angular momentum of the system around the origin in
+// kg*m^2*s^{-1}
+func (system *NBodySystem) AngularMomentum() float64 {
+	momentum := 0.0
+	for _, p := range system.particles {
+		momentum += p.Mass * p.Position.Scaled(1/PixelsPerMeter).Cross(p.Speed)
+	}
+	return momentum
+}
+
+// Drift returns relative change of total energy and angular momentum since the integrator was
+// chosen
+func (system *NBodySystem) Drift() (float64, float64) {
+	if system.integrator == nil {
+		return 0, 0
+	}
+	return (system.Energy() - system.energy) / math.Abs(system.energy),
+		(system.AngularMomentum() - system.momentum) / math.Abs(system.momentum)
+}
+
+// NewGalaxy returns disk of count particles orbiting a heavy central body at position in pixels.
+// Particles have circular speeds given by the mass enclosed by their orbits, their orbits are drawn
+// from random
+func NewGalaxy(
+	position pixel.Vec,
+	count int,
+	radius float64,
+	centralMass float64,
+	diskMass float64,
+	theta *Parameter,
+	softening *Parameter,
+	random *rand.Rand) *NBodySystem {
+	const constant = 1.0
+
+	system := NewNBodySystem(constant, theta, softening)
+	system.AddParticle(position, pixel.ZV, centralMass)
+	system.particles[0].Radius = 3 * ParticleRadius
+
+	for i := 0; i < count; i++ {
+		// uniform distribution over the area of the disk, inner part is left empty
+		r := radius * math.Sqrt(0.05+0.95*random.Float64())
+		angle := random.Float64() * 2 * math.Pi
+		offset := pixel.V(r, 0).Rotated(angle)
+
+		enclosed := centralMass + diskMass*(r*r)/(radius*radius)
+		speed := math.Sqrt(constant * enclosed / r)
+		system.AddParticle(position.Add(offset.Scaled(PixelsPerMeter)),
+			offset.Unit().Normal().Scaled(speed), diskMass/float64(count))
+	}
+	return system
+}
@@ -0,0 +1,130 @@
+package sim
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/faiface/pixel"
+)
+
+// randomBodies returns particles of random masses scattered over a square of size in pixels
+func randomBodies(count int, size float64) []Particle {
+	random := rand.New(rand.NewSource(1))
+	particles := make([]Particle, count)
+	for i := range particles {
+		particles[i] = Particle{
+			Position: pixel.V(random.Float64()*size, random.Float64()*size),
+			Speed:    pixel.V(random.NormFloat64(), random.NormFloat64()),
+			Mass:     0.5 + random.Float64(),
+		}
+	}
+	return particles
+}
+
+// directGravity returns gravitational force acting on particle i summed over all other particles
+func directGravity(particles []Particle, i int, constant float64, softening float64) pixel.Vec {
+	force := pixel.ZV
+	for j := range particles {
+		if j == i {
+			continue
+		}
+		d := particles[j].Position.Sub(particles[i].Position).Scaled(1 / PixelsPerMeter)
+		s := math.Sqrt(d.Dot(d) + softening*softening)
+		force = force.Add(d.Scaled(constant * particles[i].Mass * particles[j].Mass / (s * s * s)))
+	}
+	return force
+}
+
+// TestNbarnesHut tests forces of Barnes–Hut quadtree against direct summation
+func TestNbarnesHut(t *testing.T) {
+	particles := randomBodies(500, 800)
+	softening := Parameter{Value: 0.05}
+
+	for _, test := range []struct {
+		theta     float64
+		tolerance float64
+	}{
+		{0, 1e-9},
+		{0.5, 1e-2},
+	} {
+		theta := Parameter{Value: test.theta}
+		gravity := NBodyGravity{constant: 1, theta: &theta, softening: &softening}
+		gravity.Build(particles)
+
+		errorSum, forceSum := 0.0, 0.0
+		for i := range particles {
+			eForce := directGravity(particles, i, 1, softening.Value)
+			force := gravity.Force(&particles[i], particles[i].Position, particles[i].Speed)
+			errorSum += force.To(eForce).Len()
+			forceSum += eForce.Len()
+		}
+
+		if errorSum/forceSum > test.tolerance {
+			t.Errorf("Barnes-Hut theta=%.1f: Expected relative error under %g got %g",
+				test.theta, test.tolerance, errorSum/forceSum)
+		}
+	}
+}
+
+// TestNdrift tests drift of total energy and angular momentum of two bodies on circular orbits
+// for every integrator and that integrators of higher order drift less on eccentric orbits
+func TestNdrift(t *testing.T) {
+	theta := Parameter{Value: 0}
+	softening := Parameter{Value: 0}
+
+	// newBinary returns bodies of mass 1 kg at distance 1 m, which orbit with period 2π/√2 s for
+	// G = 1 when speed is one
+	newBinary := func(speed float64) *NBodySystem {
+		system := NewNBodySystem(1, &theta, &softening)
+		speed *= math.Sqrt(0.5)
+		system.AddParticle(pixel.V(-PixelsPerMeter/2, 0), pixel.V(0, -speed), 1)
+		system.AddParticle(pixel.V(PixelsPerMeter/2, 0), pixel.V(0, speed), 1)
+		return system
+	}
+
+	secondOrderDrift := math.Inf(1)
+	eccentricDrifts := make([]float64, len(Integrators()))
+	for n, integrator := range Integrators() {
+		system := newBinary(1)
+
+		// ten orbits
+		const dt = 0.001
+		for i := 0; i < int(20*math.Pi/math.Sqrt(2)/dt); i++ {
+			system.Update(dt, integrator)
+		}
+
+		energy, momentum := system.Drift()
+		t.Logf("%-20s energy drift %+.2e angular momentum drift %+.2e", integrator.Name(),
+			energy, momentum)
+		// implicit Euler is dissipative, all other integrators conserve both quantities
+		if _, ok := integrator.(ImplicitEulerIntegrator); ok {
+			if energy >= 0 {
+				t.Errorf("%s drift: Expected loss of energy got %g", integrator.Name(), energy)
+			}
+		} else if math.Abs(energy) > 1e-6 || math.Abs(momentum) > 1e-6 {
+			t.Errorf("%s drift: Expected drift under %g got energy %g angular momentum %g",
+				integrator.Name(), 1e-6, energy, momentum)
+		}
+
+		// the largest energy drift over ten eccentric orbits with a longer time-step
+		system = newBinary(0.8)
+		const eccentricDt = 0.01
+		for i := 0; i < int(20*math.Pi/math.Sqrt(2)/eccentricDt); i++ {
+			system.Update(eccentricDt, integrator)
+			energy, _ := system.Drift()
+			eccentricDrifts[n] = math.Max(eccentricDrifts[n], math.Abs(energy))
+		}
+		if integrator.Order() == 2 {
+			secondOrderDrift = math.Min(secondOrderDrift, eccentricDrifts[n])
+		}
+	}
+
+	// forces of all particles have to be evaluated at every stage to keep the order
+	for n, integrator := range Integrators() {
+		if integrator.Order() >= 4 && eccentricDrifts[n] > secondOrderDrift/100 {
+			t.Errorf("%s eccentric drift: Expected drift under %g got %g", integrator.Name(),
+				secondOrderDrift/100, eccentricDrifts[n])
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package sim
+
+import (
+	"math"
+	"math/rand"
+)
+
+// perlinPermutation is permutation table of the gradient noise repeated twice so the indices do
+// not have to be wrapped
+var perlinPermutation = func() [512]int {
+	var permutation [512]int
+	for i, value := range rand.New(rand.NewSource(0)).Perm(256) {
+		permutation[i] = value
+		permutation[i+256] = value
+	}
+	return permutation
+}()
+
+// perlinNoise returns value of three dimensional gradient noise of Ken Perlin at a given point.
+// Returned values are roughly in range [-1, 1] and change smoothly over distance of 1
+func perlinNoise(x, y, z float64) float64 {
+	xf, yf, zf := math.Floor(x), math.Floor(y), math.Floor(z)
+	xi, yi, zi := int(xf)&255, int(yf)&255, int(zf)&255
+	x, y, z = x-xf, y-yf, z-zf
+
+	u, v, w := perlinFade(x), perlinFade(y), perlinFade(z)
+
+	p := &perlinPermutation
+	a := p[xi] + yi
+	aa, ab := p[a]+zi, p[a+1]+zi
+	b := p[xi+1] + yi
+	ba, bb := p[b]+zi, p[b+1]+zi
+
+	return lerp(w,
+		lerp(v,
+			lerp(u, perlinGradient(p[aa], x, y, z), perlinGradient(p[ba], x-1, y, z)),
+			lerp(u, perlinGradient(p[ab], x, y-1, z), perlinGradient(p[bb], x-1, y-1, z))),
+		lerp(v,
+			lerp(u, perlinGradient(p[aa+1], x, y, z-1), perlinGradient(p[ba+1], x-1, y, z-1)),
+			lerp(u, perlinGradient(p[ab+1], x, y-1, z-1), perlinGradient(p[bb+1], x-1, y-1, z-1))))
+}
+
+// perlinFade is smoothstep polynomial 6t^5 - 15t^4 + 10t^3 used for interpolation of the noise
+func perlinFade(t float64) float64 {
+	return t * t * t * (t*(t*6-15) + 10)
+}
+
+// perlinGradient returns dot product of the vector (x, y, z) and one of twelve gradient vectors
+// selected by the hash
+func perlinGradient(hash int, x, y, z float64) float64 {
+	h := hash & 15
+	u := y
+	if h < 8 {
+		u = x
+	}
+	v := z
+	if h < 4 {
+		v = y
+	} else if h == 12 || h == 14 {
+		v = x
+	}
+	if h&1 != 0 {
+		u = -u
+	}
+	if h&2 != 0 {
+		v = -v
+	}
+	return u + v
+}
+
+func lerp(t, a, b float64) float64 {
+	return a + t*(b-a)
+}
+
+// curlNoise returns divergence free two dimensional vector field obtained as curl of the gradient
+// noise (∂ψ/∂y, -∂ψ/∂x) where ψ(x, y) = perlinNoise(x, y, z)
+func curlNoise(x, y, z float64) (float64, float64) {
+	const eps = 1e-4
+
+	dx := (perlinNoise(x+eps, y, z) - perlinNoise(x-eps, y, z)) / (2 * eps)
+	dy := (perlinNoise(x, y+eps, z) - perlinNoise(x, y-eps, z)) / (2 * eps)
+
+	return dy, -dx
+}
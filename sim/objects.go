@@ -0,0 +1,265 @@
+// Package sim simulates particles, emitters, forces, colliders, springs, position-based and rigid
+// bodies without any window or graphics, so simulations run on machines without OpenGL. Positions
+// are in pixels of the pixel library's vectors, which are plain Go. The window app is one
+// front-end drawing the state of the simulation
+package sim
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+
+	"github.com/faiface/pixel"
+)
+
+// Gravity is vector representing standard gravity accelleration vector in m*s^{-2}
+var Gravity = pixel.Vec{
+	X: 0.0,
+	Y: -9.81,
+}
+
+// PixelsPerMeter is the number of pixels on screen that represent one meter in real life
+const PixelsPerMeter = 100.0
+
+// ParticleRadius is the default radius of particles in m
+const ParticleRadius = 0.015
+
+// Particle represents particle object
+type Particle struct {
+	Position        pixel.Vec   // in pixels
+	lastPosition    pixel.Vec   // in pixels, before the last step, rendering interpolates from it
+	nextPosition    pixel.Vec   // in pixels
+	prevDt          float64     // in s
+	stepSize        float64     // in s, substep suggested by adaptive integrators
+	Speed           pixel.Vec   // in m*s^{-1}
+	Lifespan        float64     // in s
+	Alive           float64     // in s
+	Forces          *ForceField // forces acting on the particle, DefaultForces when nil
+	Mass            float64     // in kg
+	Radius          float64     // in m
+	DragCoefficient float64     // drag coefficient C_d used by NewtonDrag
+	Density         float64     // in kg*m^{-2}, calculated by Fluid
+	fluidForce      pixel.Vec   // in N, calculated by Fluid
+}
+
+// Interpolated returns position in pixels between the position before the last step and the
+// current one, alpha is fraction of the step
+func (p *Particle) Interpolated(alpha float64) pixel.Vec {
+	return pixel.Lerp(p.lastPosition, p.Position, alpha)
+}
+
+// KillOldParticles removes all particles that live up to their lifespan. When outside is set,
+// particles outside the boundaries of the view are removed too
+func (particleSystem *ParticleSystem) KillOldParticles(
+	minX float64,
+	maxX float64,
+	minY float64,
+	outside bool) {
+	particles := particleSystem.Particles()
+	// the last particle moves to the place of a removed one, so the same index is checked again
+	for i := 0; i < particles.Len(); {
+		position := particles.position[i]
+		if particles.alive[i] >= particles.lifespan[i] || outside &&
+			(position.X < minX || position.X > maxX || position.Y < minY) {
+			particles.Remove(i)
+			continue
+		}
+		i++
+	}
+}
+
+// Parameter represents parameter that controlls various parameters used by ParticleSystem
+type Parameter struct {
+	Value float64
+	Step  float64
+	Min   float64
+	Max   float64
+}
+
+// ParticleSystem represents emitter of particles with rate of particle generation per second.
+// Emitted particles get their lifespan, speed, mass, radius and drag coefficient from parameters.
+// Directions of emitted particles are drawn from a random source seeded by Seed, so runs with the
+// same seed and fixed time-step are identical
+type ParticleSystem struct {
+	Position        pixel.Vec // in pixels
+	EmitRate        *Parameter
+	Angle           *Parameter // in degrees
+	Lifespan        *Parameter // in s
+	Speed           *Parameter // in m*s^{-1}
+	Mass            *Parameter // in kg
+	Radius          *Parameter // in m
+	DragCoefficient *Parameter
+	Capacity        int        // most particles alive at once, zero for unlimited
+	Workers         int        // goroutines advancing particles at once, GOMAXPROCS when zero
+	Seed            *Parameter // seed of the random source, zero when nil
+	particles       *Particles
+	random          *rand.Rand // random source of emission, seeded when first used after Reset
+	forces          ForceField // forces acting on all particles of the system
+	integrator      Integrator // integrator the particles were advanced by
+	elapsed         float64    // in s, time not consumed by emission yet
+}
+
+// Particles returns particles of the system. Storage is created with the capacity of the system
+// when it is first used
+func (particleSystem *ParticleSystem) Particles() *Particles {
+	if particleSystem.particles == nil {
+		particleSystem.particles = NewParticles(particleSystem.Capacity, &particleSystem.forces)
+	}
+	return particleSystem.particles
+}
+
+// Forces returns forces acting on all particles of the system, so other bodies can share them
+func (particleSystem *ParticleSystem) Forces() *ForceField {
+	return &particleSystem.forces
+}
+
+// SetIntegrator chooses integrator the particles are advanced by. History of positions of all
+// particles is rebuilt when another integrator is chosen
+func (particleSystem *ParticleSystem) SetIntegrator(positionIntegrator Integrator, dt float64) {
+	if particleSystem.integrator == positionIntegrator {
+		return
+	}
+	particles := particleSystem.Particles()
+	for i := 0; i < particles.Len(); i++ {
+		particles.resetHistory(i, dt)
+	}
+	particleSystem.integrator = positionIntegrator
+}
+
+// Update advances all particles by time-step dt in s and resolves their collisions with colliders
+// of the scene, with each other, with the fluid and with the boundary
+func (particleSystem *ParticleSystem) Update(
+	dt float64,
+	positionIntegrator Integrator,
+	scene *Scene,
+	collisions *ParticleCollisions,
+	fluid *Fluid,
+	boundary *Boundary) {
+	particleSystem.SetIntegrator(positionIntegrator, dt)
+	updateParticles(particleSystem.Particles(), dt, particleSystem.Workers, positionIntegrator,
+		scene, collisions, fluid, boundary)
+}
+
+// Emit emits particles for time-step dt in s. Particles leave the position of the system in
+// random directions within the angle around the vertical. No particles are emitted while the
+// storage is full
+func (particleSystem *ParticleSystem) Emit(dt float64) {
+	particles := particleSystem.Particles()
+	random := particleSystem.randomSource()
+	particleSystem.elapsed += dt
+	timeForOneParticle := 1.0 / particleSystem.EmitRate.Value
+
+	var particle Particle
+	for particleSystem.elapsed > timeForOneParticle {
+		pos := particleSystem.Position
+		angle := (random.Float64() - 0.5) * (particleSystem.Angle.Value * (math.Pi / 180))
+		speed := pixel.V(0, particleSystem.Speed.Value).Rotated(angle)
+
+		particle = Particle{
+			Position:        pos,
+			lastPosition:    pos,
+			Speed:           speed,
+			Lifespan:        particleSystem.Lifespan.Value,
+			Alive:           0.0,
+			Forces:          &particleSystem.forces,
+			Mass:            particleSystem.Mass.Value,
+			Radius:          particleSystem.Radius.Value,
+			DragCoefficient: particleSystem.DragCoefficient.Value,
+		}
+		particle.resetHistory(dt)
+		particles.Add(particle)
+		particleSystem.elapsed -= timeForOneParticle
+	}
+}
+
+// Reset removes all particles and restarts emission. The random source is seeded again by the
+// current seed, so the run is repeated
+func (particleSystem *ParticleSystem) Reset() {
+	particleSystem.Particles().Clear()
+	particleSystem.elapsed = 0
+	particleSystem.random = nil
+}
+
+// randomSource returns random source of emission. It is seeded by the seed of the system when it
+// is first used after Reset
+func (particleSystem *ParticleSystem) randomSource() *rand.Rand {
+	if particleSystem.random == nil {
+		seed := int64(0)
+		if particleSystem.Seed != nil {
+			seed = int64(particleSystem.Seed.Value)
+		}
+		particleSystem.random = rand.New(rand.NewSource(seed))
+	}
+	return particleSystem.random
+}
+
+// updateParticles advances particles in chunks run by workers goroutines. Each particle is
+// integrated and collided with the scene independently of the others, so results do not depend on
+// the number of workers. Collisions of particles with each other, fluid and boundary are resolved
+// afterwards by one goroutine in the order of particles
+func updateParticles(
+	particles *Particles,
+	dt float64,
+	workers int,
+	positionIntegrator Integrator,
+	scene *Scene,
+	collisions *ParticleCollisions,
+	fluid *Fluid,
+	boundary *Boundary) {
+	fluid.Update(particles)
+
+	// the grid of the scene is built lazily, so it is built before workers query it at once
+	if scene.dirty {
+		scene.build()
+	}
+
+	parallel(particles.Len(), workers, func(start int, end int) {
+		// the particle escapes to the integrator, so one copy per worker is reused instead of
+		// allocating per step
+		var p Particle
+		for i := start; i < end; i++ {
+			particles.loadMotion(i, &p)
+			p.lastPosition = p.Position
+			newPosition := positionIntegrator.Step(&p, dt)
+			p.Position = collideWithColliders(&p, newPosition, dt, scene)
+			particles.storeMotion(i, &p)
+		}
+	})
+
+	collisions.Resolve(particles, dt)
+	fluid.Resolve(particles, boundary.Bounds.Min.Y, dt)
+	boundary.Resolve(particles, dt)
+
+	for i := range particles.alive {
+		particles.alive[i] += dt
+	}
+}
+
+// AddForce adds a force acting on all particles of the particle system
+func (particleSystem *ParticleSystem) AddForce(force Force) {
+	particleSystem.forces = append(particleSystem.forces, force)
+}
+
+// RemoveForce removes a force from the forces acting on particles of the particle system and
+// reports whether it was removed. Forces are compared by identity, so only forces added as a
+// pointer are removed
+func (particleSystem *ParticleSystem) RemoveForce(force Force) bool {
+	// values holding slices, maps or functions are not comparable, comparing them would panic
+	if reflect.ValueOf(force).Kind() != reflect.Ptr {
+		return false
+	}
+	for i, f := range particleSystem.forces {
+		if f == force {
+			particleSystem.forces = append(particleSystem.forces[:i], particleSystem.forces[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Circle represents colliding object
+type Circle struct {
+	Material
+	Position pixel.Vec
+	Radius   float64
+}
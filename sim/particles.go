@@ -0,0 +1,210 @@
+package sim
+
+import "github.com/faiface/pixel"
+
+// Particles represents particles of a particle system stored as parallel arrays, one array per
+// property. Removed particles are replaced by the last one, so the arrays stay dense and their
+// memory is reused by particles emitted later. Integrators and forces work with one particle at a
+// time, the properties they use are copied from the arrays to a Particle and back. The copying
+// makes one integration step slower than with a slice of structs, see BenchmarkAupdateSlice
+type Particles struct {
+	position        []pixel.Vec // in pixels
+	lastPosition    []pixel.Vec // in pixels, before the last step
+	nextPosition    []pixel.Vec // in pixels
+	prevDt          []float64   // in s
+	stepSize        []float64   // in s
+	speed           []pixel.Vec // in m*s^{-1}
+	lifespan        []float64   // in s
+	alive           []float64   // in s
+	mass            []float64   // in kg
+	radius          []float64   // in m
+	dragCoefficient []float64
+	density         []float64   // in kg*m^{-2}
+	fluidForce      []pixel.Vec // in N
+	forces          *ForceField // forces acting on all particles, DefaultForces when nil
+	capacity        int         // most particles stored, zero for unlimited
+}
+
+// NewParticles creates empty storage for at most capacity particles, zero capacity is unlimited.
+// Forces act on all stored particles
+func NewParticles(capacity int, forces *ForceField) *Particles {
+	return &Particles{capacity: capacity, forces: forces}
+}
+
+// Len returns number of particles
+func (particles *Particles) Len() int {
+	return len(particles.position)
+}
+
+// Full reports whether the number of particles reached the capacity
+func (particles *Particles) Full() bool {
+	return particles.capacity > 0 && particles.Len() >= particles.capacity
+}
+
+// Add adds a particle and reports whether there was room for it. Forces of the particle are
+// replaced by forces of the storage
+func (particles *Particles) Add(p Particle) bool {
+	if particles.Full() {
+		return false
+	}
+	particles.position = append(particles.position, p.Position)
+	particles.lastPosition = append(particles.lastPosition, p.lastPosition)
+	particles.nextPosition = append(particles.nextPosition, p.nextPosition)
+	particles.prevDt = append(particles.prevDt, p.prevDt)
+	particles.stepSize = append(particles.stepSize, p.stepSize)
+	particles.speed = append(particles.speed, p.Speed)
+	particles.lifespan = append(particles.lifespan, p.Lifespan)
+	particles.alive = append(particles.alive, p.Alive)
+	particles.mass = append(particles.mass, p.Mass)
+	particles.radius = append(particles.radius, p.Radius)
+	particles.dragCoefficient = append(particles.dragCoefficient, p.DragCoefficient)
+	particles.density = append(particles.density, p.Density)
+	particles.fluidForce = append(particles.fluidForce, p.fluidForce)
+	return true
+}
+
+// Get returns copy of the particle with index i
+func (particles *Particles) Get(i int) Particle {
+	return Particle{
+		Position:        particles.position[i],
+		lastPosition:    particles.lastPosition[i],
+		nextPosition:    particles.nextPosition[i],
+		prevDt:          particles.prevDt[i],
+		stepSize:        particles.stepSize[i],
+		Speed:           particles.speed[i],
+		Lifespan:        particles.lifespan[i],
+		Alive:           particles.alive[i],
+		Forces:          particles.forces,
+		Mass:            particles.mass[i],
+		Radius:          particles.radius[i],
+		DragCoefficient: particles.dragCoefficient[i],
+		Density:         particles.density[i],
+		fluidForce:      particles.fluidForce[i],
+	}
+}
+
+// Set replaces the particle with index i by p
+func (particles *Particles) Set(i int, p *Particle) {
+	particles.position[i] = p.Position
+	particles.lastPosition[i] = p.lastPosition
+	particles.nextPosition[i] = p.nextPosition
+	particles.prevDt[i] = p.prevDt
+	particles.stepSize[i] = p.stepSize
+	particles.speed[i] = p.Speed
+	particles.lifespan[i] = p.Lifespan
+	particles.alive[i] = p.Alive
+	particles.mass[i] = p.Mass
+	particles.radius[i] = p.Radius
+	particles.dragCoefficient[i] = p.DragCoefficient
+	particles.density[i] = p.Density
+	particles.fluidForce[i] = p.fluidForce
+}
+
+// loadMotion copies to p the properties of the particle with index i which integrators, forces
+// and colliders work with. Lifespan, age and density of p are left as they are
+func (particles *Particles) loadMotion(i int, p *Particle) {
+	p.Position = particles.position[i]
+	p.nextPosition = particles.nextPosition[i]
+	p.prevDt = particles.prevDt[i]
+	p.stepSize = particles.stepSize[i]
+	p.Speed = particles.speed[i]
+	p.Forces = particles.forces
+	p.Mass = particles.mass[i]
+	p.Radius = particles.radius[i]
+	p.DragCoefficient = particles.dragCoefficient[i]
+	p.fluidForce = particles.fluidForce[i]
+}
+
+// storeMotion stores the properties of p changed by a step to the particle with index i
+func (particles *Particles) storeMotion(i int, p *Particle) {
+	particles.position[i] = p.Position
+	particles.lastPosition[i] = p.lastPosition
+	particles.nextPosition[i] = p.nextPosition
+	particles.prevDt[i] = p.prevDt
+	particles.stepSize[i] = p.stepSize
+	particles.speed[i] = p.Speed
+}
+
+// Remove removes the particle with index i by moving the last particle in its place
+func (particles *Particles) Remove(i int) {
+	last := particles.Len() - 1
+	if i != last {
+		particles.position[i] = particles.position[last]
+		particles.lastPosition[i] = particles.lastPosition[last]
+		particles.nextPosition[i] = particles.nextPosition[last]
+		particles.prevDt[i] = particles.prevDt[last]
+		particles.stepSize[i] = particles.stepSize[last]
+		particles.speed[i] = particles.speed[last]
+		particles.lifespan[i] = particles.lifespan[last]
+		particles.alive[i] = particles.alive[last]
+		particles.mass[i] = particles.mass[last]
+		particles.radius[i] = particles.radius[last]
+		particles.dragCoefficient[i] = particles.dragCoefficient[last]
+		particles.density[i] = particles.density[last]
+		particles.fluidForce[i] = particles.fluidForce[last]
+	}
+	particles.truncate(last)
+}
+
+// Clear removes all particles and keeps the memory of the arrays
+func (particles *Particles) Clear() {
+	particles.truncate(0)
+}
+
+func (particles *Particles) truncate(n int) {
+	particles.position = particles.position[:n]
+	particles.lastPosition = particles.lastPosition[:n]
+	particles.nextPosition = particles.nextPosition[:n]
+	particles.prevDt = particles.prevDt[:n]
+	particles.stepSize = particles.stepSize[:n]
+	particles.speed = particles.speed[:n]
+	particles.lifespan = particles.lifespan[:n]
+	particles.alive = particles.alive[:n]
+	particles.mass = particles.mass[:n]
+	particles.radius = particles.radius[:n]
+	particles.dragCoefficient = particles.dragCoefficient[:n]
+	particles.density = particles.density[:n]
+	particles.fluidForce = particles.fluidForce[:n]
+}
+
+// Position returns position of the particle with index i in pixels
+func (particles *Particles) Position(i int) pixel.Vec {
+	return particles.position[i]
+}
+
+// Speed returns speed of the particle with index i in m*s^{-1}
+func (particles *Particles) Speed(i int) pixel.Vec {
+	return particles.speed[i]
+}
+
+// Radius returns radius of the particle with index i in m
+func (particles *Particles) Radius(i int) float64 {
+	return particles.radius[i]
+}
+
+// Density returns density of the fluid at the particle with index i in kg*m^{-2}
+func (particles *Particles) Density(i int) float64 {
+	return particles.density[i]
+}
+
+// Interpolated returns position of the particle with index i in pixels between the position
+// before the last step and the current one, alpha is fraction of the step
+func (particles *Particles) Interpolated(i int, alpha float64) pixel.Vec {
+	return pixel.Lerp(particles.lastPosition[i], particles.position[i], alpha)
+}
+
+// resetHistory rebuilds history of positions of the particle with index i
+func (particles *Particles) resetHistory(i int, dt float64) {
+	p := particles.Get(i)
+	p.resetHistory(dt)
+	particles.nextPosition[i] = p.nextPosition
+	particles.prevDt[i] = p.prevDt
+}
+
+// inverseMass returns inverse mass of the particle with index i in kg^{-1}
+func (particles *Particles) inverseMass(i int) float64 {
+	if particles.mass[i] <= 0 {
+		return 0
+	}
+	return 1 / particles.mass[i]
+}
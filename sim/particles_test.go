@@ -0,0 +1,233 @@
+package sim
+
+import (
+	"testing"
+
+	"github.com/faiface/pixel"
+)
+
+// benchmarkParticles is the number of particles in benchmarks
+const benchmarkParticles = 100000
+
+// integrate advances all particles by time-step dt in s
+func integrate(particles *Particles, integrator Integrator, dt float64) {
+	for i := 0; i < particles.Len(); i++ {
+		p := particles.Get(i)
+		p.Position = integrator.Step(&p, dt)
+		particles.Set(i, &p)
+	}
+}
+
+// slice returns copies of all particles
+func (particles *Particles) slice() []Particle {
+	result := make([]Particle, particles.Len())
+	for i := range result {
+		result[i] = particles.Get(i)
+	}
+	return result
+}
+
+// newBenchmarkSystem returns particle system filled with benchmarkParticles particles
+func newBenchmarkSystem() *ParticleSystem {
+	particleSystem := &ParticleSystem{
+		EmitRate:        &Parameter{Value: benchmarkParticles},
+		Angle:           &Parameter{Value: 60},
+		Lifespan:        &Parameter{Value: 10},
+		Speed:           &Parameter{Value: 5},
+		Mass:            &Parameter{Value: 0.1},
+		Radius:          &Parameter{Value: ParticleRadius},
+		DragCoefficient: &Parameter{Value: 0.47},
+		Capacity:        benchmarkParticles,
+	}
+	particleSystem.AddForce(UniformGravity{Acceleration: Gravity})
+	particleSystem.Emit(1.0001)
+	return particleSystem
+}
+
+// newBenchmarkSlice returns benchmarkParticles particles stored in a slice of structs, the storage
+// used before Particles, as a baseline of benchmarks
+func newBenchmarkSlice() ([]Particle, *ParticleSystem) {
+	particleSystem := newBenchmarkSystem()
+	return particleSystem.Particles().slice(), particleSystem
+}
+
+// TestAremove tests that removed particle is replaced by the last one
+func TestAremove(t *testing.T) {
+	particles := NewParticles(0, nil)
+	for i := 0; i < 4; i++ {
+		particles.Add(Particle{Position: pixel.V(float64(i), 0), Mass: float64(i + 1)})
+	}
+
+	particles.Remove(1)
+	if particles.Len() != 3 {
+		t.Fatalf("Remove: Expected %d particles got %d", 3, particles.Len())
+	}
+	for i, expected := range []float64{0, 3, 2} {
+		if p := particles.Get(i); p.Position.X != expected || p.Mass != expected+1 {
+			t.Errorf("Remove particle %d: Expected position of %f got %f", i, expected,
+				p.Position.X)
+		}
+	}
+
+	particles.Remove(2)
+	if particles.Len() != 2 || particles.Position(1).X != 3 {
+		t.Errorf("Remove last: Expected %d particles got %d", 2, particles.Len())
+	}
+}
+
+// TestAmotion tests that a step loads and stores only the motion of a particle
+func TestAmotion(t *testing.T) {
+	particles := NewParticles(0, nil)
+	expected := Particle{
+		Position:        pixel.V(1, 2),
+		Speed:           pixel.V(3, 4),
+		Lifespan:        5,
+		Alive:           1,
+		Mass:            0.5,
+		Radius:          0.01,
+		DragCoefficient: 0.47,
+		Density:         2,
+	}
+	particles.Add(expected)
+
+	p := Particle{Alive: 3}
+	particles.loadMotion(0, &p)
+	if p.Position != expected.Position || p.Speed != expected.Speed || p.Mass != expected.Mass ||
+		p.Alive != 3 {
+		t.Errorf("Load: Expected position of %v and age %f got %v and %f", expected.Position, 3.0,
+			p.Position, p.Alive)
+	}
+
+	p.lastPosition, p.Position, p.Speed = p.Position, pixel.V(6, 7), pixel.V(8, 9)
+	p.Mass = 2
+	particles.storeMotion(0, &p)
+	expected.lastPosition, expected.Position, expected.Speed = expected.Position, p.Position, p.Speed
+	if stored := particles.Get(0); stored != expected {
+		t.Errorf("Store: Expected particle %+v got %+v", expected, stored)
+	}
+}
+
+// TestAcapacity tests that no particles are emitted over the capacity of the system and that
+// killed particles make room for new ones without growing the arrays
+func TestAcapacity(t *testing.T) {
+	particleSystem := &ParticleSystem{
+		EmitRate:        &Parameter{Value: 100},
+		Angle:           &Parameter{Value: 60},
+		Lifespan:        &Parameter{Value: 0.5},
+		Speed:           &Parameter{Value: 1},
+		Mass:            &Parameter{Value: 1},
+		Radius:          &Parameter{Value: ParticleRadius},
+		DragCoefficient: &Parameter{Value: 0.47},
+		Capacity:        20,
+	}
+
+	particleSystem.Emit(1)
+	particles := particleSystem.Particles()
+	if particles.Len() != 20 || !particles.Full() {
+		t.Fatalf("Capacity: Expected %d particles got %d", 20, particles.Len())
+	}
+	capacity := cap(particles.position)
+
+	for step := 0; step < 100; step++ {
+		particleSystem.Update(0.01, ExplicitEulerIntegrator{}, NewScene(), &ParticleCollisions{},
+			&Fluid{}, &Boundary{})
+		particleSystem.KillOldParticles(0, 0, 0, false)
+		particleSystem.Emit(0.01)
+		if particles.Len() > 20 {
+			t.Fatalf("Capacity: Expected at most %d particles got %d", 20, particles.Len())
+		}
+	}
+
+	for i := 0; i < particles.Len(); i++ {
+		if alive := particles.Get(i).Alive; alive >= 0.5 {
+			t.Errorf("Kill: Expected particle younger than %f got %f", 0.5, alive)
+		}
+	}
+	if cap(particles.position) != capacity {
+		t.Errorf("Capacity: Expected arrays of capacity %d got %d", capacity,
+			cap(particles.position))
+	}
+}
+
+// BenchmarkAupdate measures one step of benchmarkParticles particles
+func BenchmarkAupdate(b *testing.B) {
+	particleSystem := newBenchmarkSystem()
+	// one worker as the baseline steps particles one by one
+	particleSystem.Workers = 1
+	particleSystem.SetIntegrator(ExplicitEulerIntegrator{}, 0.001)
+	scene := NewScene()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		particleSystem.Update(0.001, ExplicitEulerIntegrator{}, scene, &ParticleCollisions{},
+			&Fluid{}, &Boundary{})
+	}
+}
+
+// BenchmarkAkill measures killing and emitting a tenth of benchmarkParticles particles
+func BenchmarkAkill(b *testing.B) {
+	particleSystem := newBenchmarkSystem()
+	particles := particleSystem.Particles()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j := 0; j < particles.Len(); j += 10 {
+			particles.alive[j] = particles.lifespan[j]
+		}
+		particleSystem.KillOldParticles(0, 0, 0, false)
+		particleSystem.Emit(0.1)
+	}
+}
+
+// BenchmarkAupdateSlice measures one step of benchmarkParticles particles stored in a slice of
+// structs, which is the baseline of BenchmarkAupdate
+func BenchmarkAupdateSlice(b *testing.B) {
+	particles, _ := newBenchmarkSlice()
+	integrator := ExplicitEulerIntegrator{}
+	for i := range particles {
+		particles[i].resetHistory(0.001)
+	}
+	scene := NewScene()
+	scene.build()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j := range particles {
+			p := &particles[j]
+			p.lastPosition = p.Position
+			newPosition := integrator.Step(p, 0.001)
+			p.Position = collideWithColliders(p, newPosition, 0.001, scene)
+		}
+		for j := range particles {
+			particles[j].Alive += 0.001
+		}
+	}
+}
+
+// BenchmarkAkillSlice measures killing and emitting a tenth of benchmarkParticles particles stored
+// in a slice of structs, which is the baseline of BenchmarkAkill. Alive particles are copied to a
+// new slice as KillOldParticles did before Particles
+func BenchmarkAkillSlice(b *testing.B) {
+	particles, particleSystem := newBenchmarkSlice()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j := 0; j < len(particles); j += 10 {
+			particles[j].Alive = particles[j].Lifespan
+		}
+
+		var aliveParticles []Particle
+		for _, particle := range particles {
+			if particle.Alive < particle.Lifespan {
+				aliveParticles = append(aliveParticles, particle)
+			}
+		}
+		particles = append([]Particle{}, aliveParticles...)
+
+		// emission appends the particles emitted by the system
+		emitted := particleSystem.Particles()
+		emitted.Clear()
+		particleSystem.Emit(0.1)
+		particles = append(particles, emitted.slice()...)
+	}
+}
@@ -0,0 +1,490 @@
+package sim
+
+import (
+	"math"
+
+	"github.com/faiface/pixel"
+)
+
+// Constraint represents constraint of position-based dynamics. Constraints move predicted
+// positions of particles stored in nextPosition
+type Constraint interface {
+	// Reset is called at the beginning of every step before the constraint is solved
+	Reset()
+	// Solve moves predicted positions of particles towards satisfying the constraint
+	Solve(particles []Particle, dt float64)
+}
+
+// inverseMass returns inverse mass of a particle in kg^{-1}, particles without mass are static
+func inverseMass(p *Particle) float64 {
+	if p.Mass <= 0 {
+		return 0
+	}
+	return 1 / p.Mass
+}
+
+// projectConstraint applies one XPBD projection of constraint with value c in m and gradients
+// with respect to positions of the given particles in m. Compliance is inverse of stiffness, zero
+// compliance makes the constraint rigid. Lagrange multiplier is accumulated in lambda
+func projectConstraint(
+	particles []Particle,
+	indices []int,
+	gradients []pixel.Vec,
+	c float64,
+	compliance float64,
+	lambda *float64,
+	dt float64) {
+	// Δλ = (-C - α̃ * λ) / (Σ w_i * |∇C_i|^2 + α̃) where α̃ = α / h^2
+	alpha := compliance / (dt * dt)
+	denominator := alpha
+	for k, i := range indices {
+		denominator += inverseMass(&particles[i]) * gradients[k].Dot(gradients[k])
+	}
+	if denominator == 0 {
+		return
+	}
+
+	deltaLambda := (-c - alpha*(*lambda)) / denominator
+	*lambda += deltaLambda
+
+	// Δx_i = w_i * ∇C_i * Δλ
+	for k, i := range indices {
+		particles[i].nextPosition = particles[i].nextPosition.Add(
+			gradients[k].Scaled(inverseMass(&particles[i]) * deltaLambda * PixelsPerMeter))
+	}
+}
+
+// DistanceConstraint keeps two particles at rest length
+type DistanceConstraint struct {
+	A, B       int
+	restLength float64 // in m
+	compliance float64 // in m*N^{-1}
+	lambda     float64
+}
+
+// Reset resets Lagrange multiplier of the constraint
+func (constraint *DistanceConstraint) Reset() {
+	constraint.lambda = 0
+}
+
+// Solve projects particles of the constraint to rest length
+func (constraint *DistanceConstraint) Solve(particles []Particle, dt float64) {
+	// C = |x_a - x_b| - l
+	d := particles[constraint.A].nextPosition.Sub(
+		particles[constraint.B].nextPosition).Scaled(1 / PixelsPerMeter)
+	length := d.Len()
+	if length == 0 {
+		return
+	}
+
+	n := d.Scaled(1 / length)
+	projectConstraint(particles, []int{constraint.A, constraint.B}, []pixel.Vec{n, n.Scaled(-1)},
+		length-constraint.restLength, constraint.compliance, &constraint.lambda, dt)
+}
+
+// BendingConstraint keeps angle between segments a-b and b-c at rest angle
+type BendingConstraint struct {
+	a, b, c    int
+	restAngle  float64 // in radians
+	compliance float64 // in rad*N^{-1}*m^{-1}
+	lambda     float64
+}
+
+// bendingAngle returns signed angle between segments a-b and b-c in radians
+func bendingAngle(a pixel.Vec, b pixel.Vec, c pixel.Vec) float64 {
+	u, v := b.Sub(a), c.Sub(b)
+	return math.Atan2(u.Cross(v), u.Dot(v))
+}
+
+// Reset resets Lagrange multiplier of the constraint
+func (constraint *BendingConstraint) Reset() {
+	constraint.lambda = 0
+}
+
+// Solve projects particles of the constraint to rest angle
+func (constraint *BendingConstraint) Solve(particles []Particle, dt float64) {
+	a := particles[constraint.a].nextPosition.Scaled(1 / PixelsPerMeter)
+	b := particles[constraint.b].nextPosition.Scaled(1 / PixelsPerMeter)
+	c := particles[constraint.c].nextPosition.Scaled(1 / PixelsPerMeter)
+	u, v := b.Sub(a), c.Sub(b)
+	if u.Len() == 0 || v.Len() == 0 {
+		return
+	}
+
+	// C = θ - θ_0 wrapped to (-π, π]
+	angle := bendingAngle(a, b, c) - constraint.restAngle
+	angle = math.Remainder(angle, 2*math.Pi)
+
+	// θ = angle(v) - angle(u) where ∂angle(u)/∂u = perp(u)/|u|^2
+	gradientU := u.Normal().Scaled(1 / u.Dot(u))
+	gradientV := v.Normal().Scaled(1 / v.Dot(v))
+	projectConstraint(particles,
+		[]int{constraint.a, constraint.b, constraint.c},
+		[]pixel.Vec{gradientU, gradientU.Scaled(-1).Sub(gradientV), gradientV},
+		angle, constraint.compliance, &constraint.lambda, dt)
+}
+
+// CollisionConstraint keeps two particles from overlapping
+type CollisionConstraint struct {
+	a, b   int
+	lambda float64
+}
+
+// Reset resets Lagrange multiplier of the constraint
+func (constraint *CollisionConstraint) Reset() {
+	constraint.lambda = 0
+}
+
+// Solve pushes overlapping particles apart
+func (constraint *CollisionConstraint) Solve(particles []Particle, dt float64) {
+	// C = |x_a - x_b| - (r_a + r_b) >= 0
+	d := particles[constraint.a].nextPosition.Sub(
+		particles[constraint.b].nextPosition).Scaled(1 / PixelsPerMeter)
+	length := d.Len()
+	c := length - particles[constraint.a].Radius - particles[constraint.b].Radius
+	if c >= 0 || length == 0 {
+		return
+	}
+
+	n := d.Scaled(1 / length)
+	projectConstraint(particles, []int{constraint.a, constraint.b}, []pixel.Vec{n, n.Scaled(-1)},
+		c, 0, &constraint.lambda, dt)
+}
+
+// PinConstraint fixes particle to position in pixels
+type PinConstraint struct {
+	index    int
+	Position pixel.Vec
+}
+
+// Reset does nothing as pins are always rigid
+func (constraint *PinConstraint) Reset() {}
+
+// Solve moves the particle to the pin
+func (constraint *PinConstraint) Solve(particles []Particle, dt float64) {
+	particles[constraint.index].nextPosition = constraint.Position
+}
+
+// PositionBasedBody represents system of particles simulated by position-based dynamics. Forces
+// only predict positions of particles, constraints are then solved by iterations of XPBD
+type PositionBasedBody struct {
+	particles   []Particle
+	constraints []Constraint
+	pins        []*PinConstraint
+	Iterations  *Parameter
+	Forces      *ForceField // external forces acting on all particles, DefaultForces when nil
+
+	collisions []CollisionConstraint
+	connected  map[[2]int]bool // pairs of particles connected by distance constraints
+	hash       *SpatialHash
+}
+
+// Particles returns particles of the body
+func (body *PositionBasedBody) Particles() []Particle {
+	return body.particles
+}
+
+// Constraints returns constraints of the body except pins and collisions
+func (body *PositionBasedBody) Constraints() []Constraint {
+	return body.constraints
+}
+
+// AddParticle adds a particle at position in pixels with mass in kg and radius in m and returns
+// its index
+func (body *PositionBasedBody) AddParticle(position pixel.Vec, mass float64, radius float64) int {
+	body.particles = append(body.particles, Particle{
+		Position:     position,
+		lastPosition: position,
+		nextPosition: position,
+		Mass:         mass,
+		Radius:       radius,
+	})
+	return len(body.particles) - 1
+}
+
+// Connect adds distance constraint between particles a and b whose rest length is their current
+// distance
+func (body *PositionBasedBody) Connect(a int, b int, compliance float64) {
+	body.constraints = append(body.constraints, &DistanceConstraint{
+		A:          a,
+		B:          b,
+		restLength: body.particles[a].Position.To(body.particles[b].Position).Len() / PixelsPerMeter,
+		compliance: compliance,
+	})
+
+	if body.connected == nil {
+		body.connected = make(map[[2]int]bool)
+	}
+	body.connected[[2]int{a, b}] = true
+	body.connected[[2]int{b, a}] = true
+}
+
+// Bend adds bending constraint keeping current angle between segments a-b and b-c
+func (body *PositionBasedBody) Bend(a int, b int, c int, compliance float64) {
+	body.constraints = append(body.constraints, &BendingConstraint{
+		a: a,
+		b: b,
+		c: c,
+		restAngle: bendingAngle(body.particles[a].Position, body.particles[b].Position,
+			body.particles[c].Position),
+		compliance: compliance,
+	})
+}
+
+// Pin fixes particle with the given index at its current position and returns the pin
+func (body *PositionBasedBody) Pin(index int) *PinConstraint {
+	pin := &PinConstraint{index: index, Position: body.particles[index].Position}
+	body.pins = append(body.pins, pin)
+	return pin
+}
+
+// Unpin removes the pin
+func (body *PositionBasedBody) Unpin(pin *PinConstraint) {
+	for i, p := range body.pins {
+		if p == pin {
+			body.pins = append(body.pins[:i], body.pins[i+1:]...)
+			return
+		}
+	}
+}
+
+// PinOf returns pin of particle with the given index or nil
+func (body *PositionBasedBody) PinOf(index int) *PinConstraint {
+	for _, pin := range body.pins {
+		if pin.index == index {
+			return pin
+		}
+	}
+	return nil
+}
+
+// SetCompliance sets compliance of all distance constraints in m*N^{-1} and of all bending
+// constraints in rad*N^{-1}*m^{-1}
+func (body *PositionBasedBody) SetCompliance(distance float64, bending float64) {
+	for _, constraint := range body.constraints {
+		switch c := constraint.(type) {
+		case *DistanceConstraint:
+			c.compliance = distance
+		case *BendingConstraint:
+			c.compliance = bending
+		}
+	}
+}
+
+// ParticleAt returns index of a particle close to the given position in pixels or -1
+func (body *PositionBasedBody) ParticleAt(position pixel.Vec, distance float64) int {
+	for i := range body.particles {
+		if body.particles[i].Position.To(position).Len() <= distance+body.particles[i].Radius*
+			PixelsPerMeter {
+			return i
+		}
+	}
+	return -1
+}
+
+// Update advances the body by time-step dt in s. Particles collide with each other, with
+// colliders of the scene and with the floor at the bottom of bounds
+func (body *PositionBasedBody) Update(dt float64, scene *Scene, bounds pixel.Rect) {
+	if dt <= 0 || len(body.particles) == 0 {
+		return
+	}
+
+	// positions are predicted from forces by symplectic Euler step
+	for i := range body.particles {
+		p := &body.particles[i]
+		p.lastPosition = p.Position
+		p.Forces = body.Forces
+		if p.Mass > 0 {
+			p.Speed = p.Speed.Add(p.acceleration(p.Position, p.Speed).Scaled(dt))
+		}
+		p.nextPosition = p.Position.Add(p.Speed.Scaled(dt * PixelsPerMeter))
+	}
+
+	body.findCollisions()
+
+	for _, constraint := range body.constraints {
+		constraint.Reset()
+	}
+	for i := range body.collisions {
+		body.collisions[i].Reset()
+	}
+
+	iterations := 10
+	if body.Iterations != nil {
+		iterations = int(body.Iterations.Value)
+	}
+	for iteration := 0; iteration < iterations; iteration++ {
+		for _, constraint := range body.constraints {
+			constraint.Solve(body.particles, dt)
+		}
+		for i := range body.collisions {
+			body.collisions[i].Solve(body.particles, dt)
+		}
+		body.solveBoundaries(scene, bounds)
+		// pins are solved last so pinned particles end exactly at their pins
+		for _, pin := range body.pins {
+			pin.Solve(body.particles, dt)
+		}
+	}
+
+	// v = (x_{t+1} - x_t) / h
+	for i := range body.particles {
+		p := &body.particles[i]
+		p.Speed = p.nextPosition.Sub(p.Position).Scaled(1 / (dt * PixelsPerMeter))
+		p.Position = p.nextPosition
+	}
+}
+
+// findCollisions creates collision constraints for pairs of nearby particles which are not
+// connected by a distance constraint
+func (body *PositionBasedBody) findCollisions() {
+	body.collisions = body.collisions[:0]
+
+	maxRadius := 0.0
+	for i := range body.particles {
+		maxRadius = math.Max(maxRadius, body.particles[i].Radius)
+	}
+	if maxRadius == 0 {
+		return
+	}
+
+	// particles moving this step may start overlapping, so the search radius is enlarged
+	cellSize := 4 * maxRadius * PixelsPerMeter
+	if body.hash == nil {
+		body.hash = NewSpatialHash(cellSize)
+	}
+	body.hash.Clear(cellSize)
+	for i := range body.particles {
+		body.hash.Insert(i, body.particles[i].nextPosition)
+	}
+
+	for i := range body.particles {
+		a := &body.particles[i]
+		body.hash.Neighbours(a.nextPosition, func(j int) {
+			if j <= i || body.connected[[2]int{i, j}] {
+				return
+			}
+			b := &body.particles[j]
+			if a.nextPosition.To(b.nextPosition).Len() < 2*(a.Radius+b.Radius)*PixelsPerMeter {
+				body.collisions = append(body.collisions, CollisionConstraint{a: i, b: j})
+			}
+		})
+	}
+}
+
+// solveBoundaries pushes predicted positions out of colliders and above the floor
+func (body *PositionBasedBody) solveBoundaries(scene *Scene, bounds pixel.Rect) {
+	for i := range body.particles {
+		p := &body.particles[i]
+		if p.Mass <= 0 {
+			continue
+		}
+		radius := p.Radius * PixelsPerMeter
+
+		scene.Near(p.nextPosition, radius, func(collider Collider) {
+			closest := collider.ClosestPoint(p.nextPosition)
+			if collider.Contains(p.nextPosition) || closest.To(p.nextPosition).Len() < radius {
+				p.nextPosition = closest.Add(collider.Normal(p.nextPosition).Scaled(radius))
+			}
+		})
+
+		if p.nextPosition.Y < bounds.Min.Y+radius {
+			p.nextPosition.Y = bounds.Min.Y + radius
+		}
+	}
+}
+
+// NewPBDRope returns rope of segments links hanging from a pinned start point in pixels. The rope
+// starts stretched straight towards the end point
+func NewPBDRope(
+	start pixel.Vec,
+	end pixel.Vec,
+	segments int,
+	mass float64,
+	compliance float64,
+	bendingCompliance float64) *PositionBasedBody {
+	body := &PositionBasedBody{}
+	spacing := start.To(end).Len() / float64(segments) / PixelsPerMeter
+	for i := 0; i <= segments; i++ {
+		position := start.Add(end.Sub(start).Scaled(float64(i) / float64(segments)))
+		body.AddParticle(position, mass/float64(segments+1), spacing*0.45)
+		if i > 0 {
+			body.Connect(i-1, i, compliance)
+		}
+		if i > 1 {
+			body.Bend(i-2, i-1, i, bendingCompliance)
+		}
+	}
+	body.Pin(0)
+	return body
+}
+
+// NewRagdoll returns stick figure whose neck is at position in pixels. Bones are distance
+// constraints and joints are bending constraints, size is height of the figure in pixels
+func NewRagdoll(
+	position pixel.Vec,
+	size float64,
+	mass float64,
+	compliance float64,
+	bendingCompliance float64) *PositionBasedBody {
+	body := &PositionBasedBody{}
+	joints := []pixel.Vec{
+		{X: 0, Y: 0.12},     // 0 head
+		{X: 0, Y: 0},        // 1 neck
+		{X: 0, Y: -0.35},    // 2 pelvis
+		{X: -0.15, Y: -0.1}, // 3 left elbow
+		{X: -0.3, Y: -0.2},  // 4 left hand
+		{X: 0.15, Y: -0.1},  // 5 right elbow
+		{X: 0.3, Y: -0.2},   // 6 right hand
+		{X: -0.1, Y: -0.6},  // 7 left knee
+		{X: -0.15, Y: -0.85},
+		{X: 0.1, Y: -0.6}, // 9 right knee
+		{X: 0.15, Y: -0.85},
+	}
+	radius := 0.05 * size / PixelsPerMeter
+	for _, joint := range joints {
+		body.AddParticle(position.Add(joint.Scaled(size)), mass/float64(len(joints)), radius)
+	}
+	body.particles[0].Radius = 2 * radius
+
+	for _, bone := range [][2]int{{0, 1}, {1, 2}, {1, 3}, {3, 4}, {1, 5}, {5, 6}, {2, 7}, {7, 8},
+		{2, 9}, {9, 10}} {
+		body.Connect(bone[0], bone[1], compliance)
+	}
+	// shoulders and hips are kept apart so limbs do not fold into the torso
+	for _, pair := range [][2]int{{3, 5}, {7, 9}, {3, 2}, {5, 2}} {
+		body.Connect(pair[0], pair[1], compliance)
+	}
+
+	for _, joint := range [][3]int{{0, 1, 2}, {1, 3, 4}, {1, 5, 6}, {2, 7, 8}, {2, 9, 10}} {
+		body.Bend(joint[0], joint[1], joint[2], bendingCompliance)
+	}
+	return body
+}
+
+// NewSoftBody returns ring of segments particles around a centre particle at position in pixels.
+// Spokes and rim are distance constraints and the rim keeps its shape by bending constraints
+func NewSoftBody(
+	position pixel.Vec,
+	radius float64,
+	segments int,
+	mass float64,
+	compliance float64,
+	bendingCompliance float64) *PositionBasedBody {
+	body := &PositionBasedBody{}
+	rim := 2 * math.Pi * radius / float64(segments) / PixelsPerMeter
+	centre := body.AddParticle(position, mass/float64(segments+1), rim*0.45)
+	for i := 0; i < segments; i++ {
+		angle := 2 * math.Pi * float64(i) / float64(segments)
+		body.AddParticle(position.Add(pixel.V(radius, 0).Rotated(angle)),
+			mass/float64(segments+1), rim*0.45)
+	}
+
+	for i := 0; i < segments; i++ {
+		current, next, nextNext := 1+i, 1+(i+1)%segments, 1+(i+2)%segments
+		body.Connect(centre, current, compliance)
+		body.Connect(current, next, compliance)
+		body.Bend(current, next, nextNext, bendingCompliance)
+	}
+	return body
+}
@@ -0,0 +1,89 @@
+package sim
+
+import (
+	"math"
+	"testing"
+
+	"github.com/faiface/pixel"
+)
+
+// TestPrope tests that rigid rope does not explode and keeps the length of its links within a
+// few percent at the time-steps of the window loop
+func TestPrope(t *testing.T) {
+	iterations := Parameter{Value: 50}
+	body := NewPBDRope(pixel.V(0, 500), pixel.V(400, 500), 20, 0.3, 0, 0.05)
+	body.Iterations = &iterations
+
+	for i := 0; i < 300; i++ {
+		body.Update(1.0/30, NewScene(), pixel.R(-1e6, -1e6, 1e6, 1e6))
+	}
+
+	for _, constraint := range body.constraints {
+		distance, ok := constraint.(*DistanceConstraint)
+		if !ok {
+			continue
+		}
+		length := body.particles[distance.A].Position.To(
+			body.particles[distance.B].Position).Len() / PixelsPerMeter
+		if math.IsNaN(length) || math.Abs(length-distance.restLength) > 0.05*distance.restLength {
+			t.Errorf("Rope: Expected link length of %f got %f", distance.restLength, length)
+		}
+	}
+
+	if pinned := body.particles[0].Position; pinned != pixel.V(0, 500) {
+		t.Errorf("Rope: Expected pinned particle at %v got %v", pixel.V(0, 500), pinned)
+	}
+}
+
+// TestPbending tests that rigid bending constraint restores its rest angle
+func TestPbending(t *testing.T) {
+	body := &PositionBasedBody{Forces: &ForceField{}}
+	body.AddParticle(pixel.V(0, 0), 1, 0.01)
+	body.AddParticle(pixel.V(10, 0), 1, 0.01)
+	body.AddParticle(pixel.V(20, 0), 1, 0.01)
+	body.Connect(0, 1, 0)
+	body.Connect(1, 2, 0)
+	body.Bend(0, 1, 2, 0)
+
+	body.particles[2].Position = pixel.V(17, 7)
+	for i := 0; i < 20; i++ {
+		body.Update(0.01, NewScene(), pixel.R(-1e6, -1e6, 1e6, 1e6))
+	}
+
+	p := body.particles
+	if angle := bendingAngle(p[0].Position, p[1].Position, p[2].Position); math.Abs(angle) > 1e-3 {
+		t.Errorf("Bending: Expected angle of %f got %f", 0.0, angle)
+	}
+}
+
+// TestPcollision tests that colliding particles are separated and momentum is conserved
+func TestPcollision(t *testing.T) {
+	body := &PositionBasedBody{Forces: &ForceField{}}
+	body.AddParticle(pixel.V(0, 0), 1, 0.05)
+	body.AddParticle(pixel.V(12, 3), 3, 0.1)
+	body.particles[0].Speed = pixel.V(2, 0)
+
+	eMomentum := momentum(body.particles)
+	body.Update(0.01, NewScene(), pixel.R(-1e6, -1e6, 1e6, 1e6))
+
+	if d := body.particles[0].Position.To(body.particles[1].Position).Len(); d < 15-1e-9 {
+		t.Errorf("Collision: Expected distance of at least %f got %f", 15.0, d)
+	}
+	if diff := momentum(body.particles).To(eMomentum).Len(); diff > 1e-9 {
+		t.Errorf("Collision: Expected momentum of %f got %f", eMomentum, momentum(body.particles))
+	}
+}
+
+// TestPfloor tests that a particle falling on the floor comes to rest on it
+func TestPfloor(t *testing.T) {
+	body := &PositionBasedBody{}
+	body.AddParticle(pixel.V(0, 100), 1, 0.05)
+
+	for i := 0; i < 100; i++ {
+		body.Update(1.0/30, NewScene(), pixel.R(-1e6, 0, 1e6, 1e6))
+	}
+
+	if y := body.particles[0].Position.Y; math.Abs(y-5) > 1e-9 {
+		t.Errorf("Floor: Expected height of %f got %f", 5.0, y)
+	}
+}
@@ -0,0 +1,681 @@
+package sim
+
+import (
+	"math"
+
+	"github.com/faiface/pixel"
+)
+
+const (
+	// rigidBaumgarte is the fraction of penetration removed in one step
+	rigidBaumgarte = 0.2
+	// rigidSlop is penetration in m which is allowed so resting contacts do not jitter
+	rigidSlop = 0.005
+	// rigidBounceThreshold is approaching speed in m*s^{-1} under which contacts do not bounce
+	rigidBounceThreshold = 0.5
+)
+
+// RigidBody represents 2D rigid body which is either a circle or a convex polygon. Bodies without
+// mass are static
+type RigidBody struct {
+	Position     pixel.Vec   // centre of mass in pixels
+	Angle        float64     // in radians
+	Speed        pixel.Vec   // in m*s^{-1}
+	AngularSpeed float64     // in rad*s^{-1}
+	Radius       float64     // in pixels, used by circles
+	Vertices     []pixel.Vec // in pixels relative to the centre of mass, counter-clockwise
+	Mass         float64     // in kg
+	inertia      float64     // moment of inertia in kg*m^2
+	friction     float64     // coefficient of Coulomb friction
+	restitution  float64     // coefficient of restitution
+	lastPosition pixel.Vec   // in pixels, before the last step, rendering interpolates from it
+	lastAngle    float64     // in radians, before the last step
+}
+
+// NewRigidCircle returns circle with radius in pixels and density in kg*m^{-2}, zero density
+// makes the body static
+func NewRigidCircle(position pixel.Vec, radius float64, density float64) *RigidBody {
+	r := radius / PixelsPerMeter
+	mass := density * math.Pi * r * r
+	return &RigidBody{
+		Position: position,
+		Radius:   radius,
+		Mass:     mass,
+		inertia:  mass * r * r / 2,
+	}
+}
+
+// NewRigidBox returns box with width and height in pixels and density in kg*m^{-2}, zero density
+// makes the body static
+func NewRigidBox(position pixel.Vec, width float64, height float64, density float64) *RigidBody {
+	return NewRigidPolygon(position, []pixel.Vec{
+		pixel.V(-width/2, -height/2),
+		pixel.V(width/2, -height/2),
+		pixel.V(width/2, height/2),
+		pixel.V(-width/2, height/2),
+	}, density)
+}
+
+// NewRigidPolygon returns convex polygon with counter-clockwise vertices in pixels relative to
+// position and density in kg*m^{-2}, zero density makes the body static. The polygon is moved so
+// position is it's centre of mass
+func NewRigidPolygon(position pixel.Vec, vertices []pixel.Vec, density float64) *RigidBody {
+	// area, centroid and moment of inertia are summed over triangles of a fan around the origin
+	area, centroid, inertia := 0.0, pixel.ZV, 0.0
+	for i := range vertices {
+		a := vertices[i].Scaled(1 / PixelsPerMeter)
+		b := vertices[(i+1)%len(vertices)].Scaled(1 / PixelsPerMeter)
+		cross := a.Cross(b)
+		area += cross / 2
+		centroid = centroid.Add(a.Add(b).Scaled(cross / 6))
+		inertia += cross * (a.Dot(a) + a.Dot(b) + b.Dot(b)) / 12
+	}
+	centroid = centroid.Scaled(1 / area)
+
+	body := &RigidBody{
+		Position: position.Add(centroid.Scaled(PixelsPerMeter)),
+		Vertices: make([]pixel.Vec, len(vertices)),
+		Mass:     density * area,
+	}
+	for i := range vertices {
+		body.Vertices[i] = vertices[i].Sub(centroid.Scaled(PixelsPerMeter))
+	}
+	// parallel axis theorem moves the moment of inertia to the centre of mass
+	body.inertia = density*inertia - body.Mass*centroid.Dot(centroid)
+	return body
+}
+
+// NewRigidRegularPolygon returns regular polygon with count vertices at distance radius in
+// pixels from its centre and density in kg*m^{-2}
+func NewRigidRegularPolygon(
+	position pixel.Vec,
+	radius float64,
+	count int,
+	density float64) *RigidBody {
+	vertices := make([]pixel.Vec, count)
+	for i := range vertices {
+		vertices[i] = pixel.V(radius, 0).Rotated(2 * math.Pi * float64(i) / float64(count))
+	}
+	return NewRigidPolygon(position, vertices, density)
+}
+
+func (body *RigidBody) inverseMass() float64 {
+	if body.Mass <= 0 {
+		return 0
+	}
+	return 1 / body.Mass
+}
+
+func (body *RigidBody) inverseInertia() float64 {
+	if body.Mass <= 0 || body.inertia <= 0 {
+		return 0
+	}
+	return 1 / body.inertia
+}
+
+// IsCircle reports whether the body is a circle
+func (body *RigidBody) IsCircle() bool {
+	return len(body.Vertices) == 0
+}
+
+// Interpolated returns position in pixels and angle in radians between the state before the last
+// step and the current one, alpha is fraction of the step
+func (body *RigidBody) Interpolated(alpha float64) (pixel.Vec, float64) {
+	return pixel.Lerp(body.lastPosition, body.Position, alpha),
+		lerp(alpha, body.lastAngle, body.Angle)
+}
+
+// worldVertices returns vertices of the polygon in m
+func (body *RigidBody) worldVertices() []pixel.Vec {
+	vertices := make([]pixel.Vec, len(body.Vertices))
+	for i, vertex := range body.Vertices {
+		vertices[i] = vertex.Rotated(body.Angle).Add(body.Position).Scaled(1 / PixelsPerMeter)
+	}
+	return vertices
+}
+
+// bounds returns bounding box of the body in pixels
+func (body *RigidBody) bounds() pixel.Rect {
+	if body.IsCircle() {
+		return pixel.R(body.Position.X-body.Radius, body.Position.Y-body.Radius,
+			body.Position.X+body.Radius, body.Position.Y+body.Radius)
+	}
+
+	vertices := body.worldVertices()
+	bounds := pixel.R(vertices[0].X, vertices[0].Y, vertices[0].X, vertices[0].Y)
+	for _, vertex := range vertices {
+		bounds.Min = pixel.V(math.Min(bounds.Min.X, vertex.X), math.Min(bounds.Min.Y, vertex.Y))
+		bounds.Max = pixel.V(math.Max(bounds.Max.X, vertex.X), math.Max(bounds.Max.Y, vertex.Y))
+	}
+	return pixel.R(bounds.Min.X*PixelsPerMeter, bounds.Min.Y*PixelsPerMeter,
+		bounds.Max.X*PixelsPerMeter, bounds.Max.Y*PixelsPerMeter)
+}
+
+// velocityAt returns velocity in m*s^{-1} of the point of the body at r in m from it's centre
+func (body *RigidBody) velocityAt(r pixel.Vec) pixel.Vec {
+	// v + ω × r
+	return body.Speed.Add(r.Normal().Scaled(body.AngularSpeed))
+}
+
+// applyImpulse applies impulse in N*s at point r in m from the centre of the body
+func (body *RigidBody) applyImpulse(impulse pixel.Vec, r pixel.Vec) {
+	body.Speed = body.Speed.Add(impulse.Scaled(body.inverseMass()))
+	body.AngularSpeed += r.Cross(impulse) * body.inverseInertia()
+}
+
+// collideCircle returns normal pointing from the body to a circle with centre and radius in m,
+// penetration depth in m and the point of the contact in m. It reports false when they do not
+// touch
+func (body *RigidBody) collideCircle(
+	center pixel.Vec,
+	radius float64) (pixel.Vec, float64, pixel.Vec, bool) {
+	if body.IsCircle() {
+		d := center.Sub(body.Position.Scaled(1 / PixelsPerMeter))
+		distance := d.Len()
+		depth := body.Radius/PixelsPerMeter + radius - distance
+		if depth <= 0 {
+			return pixel.ZV, 0, pixel.ZV, false
+		}
+		normal := d.Unit()
+		return normal, depth, center.Sub(normal.Scaled(radius - depth/2)), true
+	}
+
+	// face of the polygon with the largest separation from the centre
+	vertices := body.worldVertices()
+	maxSeparation, face := math.Inf(-1), 0
+	for i := range vertices {
+		normal := polygonNormal(vertices, i)
+		separation := normal.Dot(center.Sub(vertices[i]))
+		if separation > radius {
+			return pixel.ZV, 0, pixel.ZV, false
+		}
+		if separation > maxSeparation {
+			maxSeparation, face = separation, i
+		}
+	}
+
+	// centre inside of the polygon is pushed out through the nearest face
+	if maxSeparation <= 0 {
+		normal := polygonNormal(vertices, face)
+		return normal, radius - maxSeparation, center.Sub(normal.Scaled(radius)), true
+	}
+
+	a, b := vertices[face], vertices[(face+1)%len(vertices)]
+	closest := closestPointOnSegment(center, a, b)
+	d := center.Sub(closest)
+	distance := d.Len()
+	if distance > radius || distance == 0 {
+		return pixel.ZV, 0, pixel.ZV, false
+	}
+	return d.Scaled(1 / distance), radius - distance, closest, true
+}
+
+// polygonNormal returns outward unit normal of face i of a counter-clockwise polygon
+func polygonNormal(vertices []pixel.Vec, i int) pixel.Vec {
+	edge := vertices[(i+1)%len(vertices)].Sub(vertices[i])
+	return pixel.V(edge.Y, -edge.X).Unit()
+}
+
+// closestPointOnSegment returns point of segment a-b closest to point p
+func closestPointOnSegment(p pixel.Vec, a pixel.Vec, b pixel.Vec) pixel.Vec {
+	ab := b.Sub(a)
+	if ab.Dot(ab) == 0 {
+		return a
+	}
+	t := math.Min(math.Max(p.Sub(a).Dot(ab)/ab.Dot(ab), 0), 1)
+	return a.Add(ab.Scaled(t))
+}
+
+// rigidContactPoint represents point of contact between two bodies and impulses accumulated by
+// the solver
+type rigidContactPoint struct {
+	position       pixel.Vec // in m
+	depth          float64   // in m
+	normalImpulse  float64   // in N*s
+	tangentImpulse float64   // in N*s
+	normalMass     float64
+	tangentMass    float64
+	bias           float64 // target normal speed in m*s^{-1}
+}
+
+// rigidContact represents contact between bodies a and b with normal pointing from a to b
+type rigidContact struct {
+	a, b   *RigidBody
+	normal pixel.Vec
+	points []rigidContactPoint
+}
+
+// collideBodies returns contact between two bodies
+func collideBodies(a *RigidBody, b *RigidBody) (rigidContact, bool) {
+	contact := rigidContact{a: a, b: b}
+
+	switch {
+	case b.IsCircle():
+		normal, depth, point, ok := a.collideCircle(b.Position.Scaled(1/PixelsPerMeter),
+			b.Radius/PixelsPerMeter)
+		if !ok {
+			return contact, false
+		}
+		contact.normal = normal
+		contact.points = []rigidContactPoint{{position: point, depth: depth}}
+	case a.IsCircle():
+		normal, depth, point, ok := b.collideCircle(a.Position.Scaled(1/PixelsPerMeter),
+			a.Radius/PixelsPerMeter)
+		if !ok {
+			return contact, false
+		}
+		contact.normal = normal.Scaled(-1)
+		contact.points = []rigidContactPoint{{position: point, depth: depth}}
+	default:
+		return collidePolygons(a, b)
+	}
+	return contact, true
+}
+
+// maxSeparation returns face of polygon a along which polygon b is separated the most
+func maxSeparation(a []pixel.Vec, b []pixel.Vec) (int, float64) {
+	bestFace, bestSeparation := 0, math.Inf(-1)
+	for i := range a {
+		normal := polygonNormal(a, i)
+		separation := math.Inf(1)
+		for _, vertex := range b {
+			separation = math.Min(separation, normal.Dot(vertex.Sub(a[i])))
+		}
+		if separation > bestSeparation {
+			bestFace, bestSeparation = i, separation
+		}
+	}
+	return bestFace, bestSeparation
+}
+
+// collidePolygons finds contact of two convex polygons by separating axis test and clips the
+// incident face against the reference face to get up to two contact points
+func collidePolygons(a *RigidBody, b *RigidBody) (rigidContact, bool) {
+	contact := rigidContact{a: a, b: b}
+	verticesA, verticesB := a.worldVertices(), b.worldVertices()
+
+	faceA, separationA := maxSeparation(verticesA, verticesB)
+	if separationA > 0 {
+		return contact, false
+	}
+	faceB, separationB := maxSeparation(verticesB, verticesA)
+	if separationB > 0 {
+		return contact, false
+	}
+
+	reference, incident, face, flip := verticesA, verticesB, faceA, false
+	// small preference of a keeps the reference face from flickering between similar faces
+	if separationB > separationA+0.001 {
+		reference, incident, face, flip = verticesB, verticesA, faceB, true
+	}
+
+	normal := polygonNormal(reference, face)
+
+	// incident face is the face of the other polygon most anti-parallel to the reference normal
+	incidentFace, minDot := 0, math.Inf(1)
+	for i := range incident {
+		if dot := polygonNormal(incident, i).Dot(normal); dot < minDot {
+			incidentFace, minDot = i, dot
+		}
+	}
+
+	v1, v2 := reference[face], reference[(face+1)%len(reference)]
+	tangent := v2.Sub(v1).Unit()
+	points := []pixel.Vec{incident[incidentFace], incident[(incidentFace+1)%len(incident)]}
+
+	// the incident face is clipped by side planes of the reference face
+	points = clipSegment(points, tangent.Scaled(-1), -tangent.Dot(v1))
+	if len(points) < 2 {
+		return contact, false
+	}
+	points = clipSegment(points, tangent, tangent.Dot(v2))
+	if len(points) < 2 {
+		return contact, false
+	}
+
+	for _, point := range points {
+		if separation := normal.Dot(point.Sub(v1)); separation <= 0 {
+			contact.points = append(contact.points, rigidContactPoint{
+				position: point,
+				depth:    -separation,
+			})
+		}
+	}
+
+	contact.normal = normal
+	if flip {
+		contact.normal = normal.Scaled(-1)
+	}
+	return contact, len(contact.points) > 0
+}
+
+// clipSegment returns part of segment given by points which satisfies normal . x <= offset
+func clipSegment(points []pixel.Vec, normal pixel.Vec, offset float64) []pixel.Vec {
+	distance0 := normal.Dot(points[0]) - offset
+	distance1 := normal.Dot(points[1]) - offset
+
+	var clipped []pixel.Vec
+	if distance0 <= 0 {
+		clipped = append(clipped, points[0])
+	}
+	if distance1 <= 0 {
+		clipped = append(clipped, points[1])
+	}
+	if distance0*distance1 < 0 {
+		t := distance0 / (distance0 - distance1)
+		clipped = append(clipped, points[0].Add(points[1].Sub(points[0]).Scaled(t)))
+	}
+	return clipped
+}
+
+// RigidWorld represents rigid bodies which collide with each other. Contacts are resolved by
+// sequential impulses with accumulated clamping
+type RigidWorld struct {
+	bodies     []*RigidBody
+	obstacles  []*RigidBody // static bodies of colliders
+	gravity    pixel.Vec    // in m*s^{-2}
+	Iterations *Parameter   // number of iterations of the solver
+	contacts   []rigidContact
+}
+
+// Bodies returns dynamic bodies of the world and static bodies of its room
+func (world *RigidWorld) Bodies() []*RigidBody {
+	return world.bodies
+}
+
+// Add adds a body to the world and returns it
+func (world *RigidWorld) Add(body *RigidBody) *RigidBody {
+	body.lastPosition, body.lastAngle = body.Position, body.Angle
+	world.bodies = append(world.bodies, body)
+	return body
+}
+
+// rigidShape is implemented by colliders which rigid bodies collide with
+type rigidShape interface {
+	// rigidBody returns static rigid body of the same shape as the collider
+	rigidBody() *RigidBody
+}
+
+func (circle *Circle) rigidBody() *RigidBody {
+	return NewRigidCircle(circle.Position, circle.Radius, 0)
+}
+
+func (polygon *ConvexPolygon) rigidBody() *RigidBody {
+	return NewRigidPolygon(pixel.ZV, polygon.Vertices, 0)
+}
+
+func (box *AxisAlignedBox) rigidBody() *RigidBody {
+	return NewRigidBox(box.Min.Add(box.Max).Scaled(0.5), box.Max.X-box.Min.X,
+		box.Max.Y-box.Min.Y, 0)
+}
+
+func (box *OrientedBox) rigidBody() *RigidBody {
+	body := NewRigidBox(box.Center, 2*box.HalfSize.X, 2*box.HalfSize.Y, 0)
+	body.Angle = box.Angle
+	return body
+}
+
+// SetObstacles replaces static obstacles of the world by bodies of colliders with their
+// materials. Colliders which are not circles or convex polygons are ignored. Existing bodies are
+// reused, so contacts with them stay warm started
+func (world *RigidWorld) SetObstacles(colliders []Collider) {
+	count := 0
+	for _, collider := range colliders {
+		shape, ok := collider.(rigidShape)
+		if !ok {
+			continue
+		}
+		if count < len(world.obstacles) {
+			*world.obstacles[count] = *shape.rigidBody()
+		} else {
+			world.obstacles = append(world.obstacles, shape.rigidBody())
+		}
+		world.obstacles[count].friction = collider.Surface().Friction
+		world.obstacles[count].restitution = collider.Surface().Restitution
+		count++
+	}
+	world.obstacles = world.obstacles[:count]
+}
+
+// SetMaterial sets friction and restitution of all bodies except obstacles
+func (world *RigidWorld) SetMaterial(friction float64, restitution float64) {
+	for _, body := range world.bodies {
+		body.friction = friction
+		body.restitution = restitution
+	}
+}
+
+// Update advances the world by time-step dt in s
+func (world *RigidWorld) Update(dt float64) {
+	if dt <= 0 {
+		return
+	}
+
+	for _, body := range world.bodies {
+		body.lastPosition, body.lastAngle = body.Position, body.Angle
+		if body.Mass > 0 {
+			body.Speed = body.Speed.Add(world.gravity.Scaled(dt))
+		}
+	}
+
+	world.findContacts()
+	for i := range world.contacts {
+		world.prepareContact(&world.contacts[i], dt)
+	}
+
+	iterations := 10
+	if world.Iterations != nil {
+		iterations = int(world.Iterations.Value)
+	}
+	for iteration := 0; iteration < iterations; iteration++ {
+		for i := range world.contacts {
+			world.solveContact(&world.contacts[i])
+		}
+	}
+
+	for _, body := range world.bodies {
+		body.Position = body.Position.Add(body.Speed.Scaled(dt * PixelsPerMeter))
+		body.Angle += body.AngularSpeed * dt
+	}
+}
+
+// findContacts finds contacts of all pairs of bodies with overlapping bounding boxes. Impulses of
+// points which persist from the last step are kept to warm start the solver
+func (world *RigidWorld) findContacts() {
+	previous := make(map[[2]*RigidBody][]rigidContactPoint, len(world.contacts))
+	for _, contact := range world.contacts {
+		previous[[2]*RigidBody{contact.a, contact.b}] = contact.points
+	}
+
+	world.contacts = world.contacts[:0]
+	bodies := append(append([]*RigidBody{}, world.bodies...), world.obstacles...)
+	for i, a := range bodies {
+		boundsA := a.bounds()
+		for _, b := range bodies[i+1:] {
+			if a.Mass <= 0 && b.Mass <= 0 {
+				continue
+			}
+			boundsB := b.bounds()
+			if boundsA.Max.X < boundsB.Min.X || boundsB.Max.X < boundsA.Min.X ||
+				boundsA.Max.Y < boundsB.Min.Y || boundsB.Max.Y < boundsA.Min.Y {
+				continue
+			}
+			if contact, ok := collideBodies(a, b); ok {
+				warmStart(contact.points, previous[[2]*RigidBody{a, b}])
+				world.contacts = append(world.contacts, contact)
+			}
+		}
+	}
+}
+
+// warmStart copies impulses of previous contact points to the nearby current points
+func warmStart(points []rigidContactPoint, previous []rigidContactPoint) {
+	const distance = 0.02 // in m
+
+	for i := range points {
+		for _, old := range previous {
+			if points[i].position.To(old.position).Len() < distance {
+				points[i].normalImpulse = old.normalImpulse
+				points[i].tangentImpulse = old.tangentImpulse
+				break
+			}
+		}
+	}
+}
+
+// prepareContact calculates effective masses and target speeds of contact points
+func (world *RigidWorld) prepareContact(contact *rigidContact, dt float64) {
+	a, b := contact.a, contact.b
+	normal := contact.normal
+	tangent := normal.Normal()
+	restitution := math.Max(a.restitution, b.restitution)
+
+	for i := range contact.points {
+		point := &contact.points[i]
+		rA := point.position.Sub(a.Position.Scaled(1 / PixelsPerMeter))
+		rB := point.position.Sub(b.Position.Scaled(1 / PixelsPerMeter))
+
+		// K = 1/m_a + 1/m_b + (r_a × n)^2/I_a + (r_b × n)^2/I_b
+		mass := func(direction pixel.Vec) float64 {
+			k := a.inverseMass() + b.inverseMass() +
+				math.Pow(rA.Cross(direction), 2)*a.inverseInertia() +
+				math.Pow(rB.Cross(direction), 2)*b.inverseInertia()
+			if k == 0 {
+				return 0
+			}
+			return 1 / k
+		}
+		point.normalMass = mass(normal)
+		point.tangentMass = mass(tangent)
+
+		// penetration is removed by Baumgarte stabilisation, fast contacts bounce
+		point.bias = rigidBaumgarte / dt * math.Max(0, point.depth-rigidSlop)
+		approach := b.velocityAt(rB).Sub(a.velocityAt(rA)).Dot(normal)
+		if approach < -rigidBounceThreshold {
+			point.bias = math.Max(point.bias, -restitution*approach)
+		}
+
+		impulse := normal.Scaled(point.normalImpulse).Add(tangent.Scaled(point.tangentImpulse))
+		a.applyImpulse(impulse.Scaled(-1), rA)
+		b.applyImpulse(impulse, rB)
+	}
+}
+
+// solveContact applies one iteration of sequential impulses to the contact
+func (world *RigidWorld) solveContact(contact *rigidContact) {
+	a, b := contact.a, contact.b
+	normal := contact.normal
+	tangent := normal.Normal()
+	friction := math.Sqrt(a.friction * b.friction)
+
+	for i := range contact.points {
+		point := &contact.points[i]
+		rA := point.position.Sub(a.Position.Scaled(1 / PixelsPerMeter))
+		rB := point.position.Sub(b.Position.Scaled(1 / PixelsPerMeter))
+
+		// friction impulse is clamped by the friction cone |λ_t| <= μ * λ_n
+		relative := b.velocityAt(rB).Sub(a.velocityAt(rA))
+		impulse := -relative.Dot(tangent) * point.tangentMass
+		limit := friction * point.normalImpulse
+		previous := point.tangentImpulse
+		point.tangentImpulse = math.Min(math.Max(previous+impulse, -limit), limit)
+		impulse = point.tangentImpulse - previous
+		a.applyImpulse(tangent.Scaled(-impulse), rA)
+		b.applyImpulse(tangent.Scaled(impulse), rB)
+
+		// accumulated normal impulse never pulls the bodies together
+		relative = b.velocityAt(rB).Sub(a.velocityAt(rA))
+		impulse = (point.bias - relative.Dot(normal)) * point.normalMass
+		previous = point.normalImpulse
+		point.normalImpulse = math.Max(previous+impulse, 0)
+		impulse = point.normalImpulse - previous
+		a.applyImpulse(normal.Scaled(-impulse), rA)
+		b.applyImpulse(normal.Scaled(impulse), rB)
+	}
+}
+
+// CollideParticles bounces particles off the bodies. Momentum of the particles is transferred to
+// the bodies, so the particles push them
+func (world *RigidWorld) CollideParticles(particles *Particles, dt float64) {
+	for _, body := range world.bodies {
+		bounds := body.bounds()
+		for i := range particles.position {
+			position, speed := &particles.position[i], &particles.speed[i]
+			radius := particles.radius[i] * PixelsPerMeter
+			if position.X+radius < bounds.Min.X || position.X-radius > bounds.Max.X ||
+				position.Y+radius < bounds.Min.Y || position.Y-radius > bounds.Max.Y {
+				continue
+			}
+
+			normal, depth, point, ok := body.collideCircle(position.Scaled(1/PixelsPerMeter),
+				particles.radius[i])
+			if !ok {
+				continue
+			}
+
+			*position = position.Add(normal.Scaled(depth * PixelsPerMeter))
+
+			// j = -(1 + e) * (v_rel . n) / (1/m_p + 1/m_b + (r × n)^2/I)
+			r := point.Sub(body.Position.Scaled(1 / PixelsPerMeter))
+			approach := speed.Sub(body.velocityAt(r)).Dot(normal)
+			if approach < 0 {
+				k := particles.inverseMass(i) + body.inverseMass() +
+					math.Pow(r.Cross(normal), 2)*body.inverseInertia()
+				impulse := -(1 + body.restitution) * approach / k
+				*speed = speed.Add(normal.Scaled(impulse * particles.inverseMass(i)))
+				body.applyImpulse(normal.Scaled(-impulse), r)
+			}
+			particles.resetHistory(i, dt)
+		}
+	}
+}
+
+// NewRigidStack returns world with pyramid of boxes standing on a static floor at the bottom of
+// bounds in pixels between static walls
+func NewRigidStack(bounds pixel.Rect, rows int, size float64) *RigidWorld {
+	world := newRigidRoom(bounds)
+	center := bounds.Center().X
+	for row := 0; row < rows; row++ {
+		for column := 0; column <= rows-row-1; column++ {
+			x := center + (float64(column)-float64(rows-row-1)/2)*size*1.05
+			y := bounds.Min.Y + size/2 + float64(row)*size
+			world.Add(NewRigidBox(pixel.V(x, y), size, size, 10))
+		}
+	}
+	return world
+}
+
+// NewRigidPile returns world with circles, boxes and polygons falling on a static floor at the
+// bottom of bounds in pixels between static walls
+func NewRigidPile(bounds pixel.Rect, count int, size float64) *RigidWorld {
+	world := newRigidRoom(bounds)
+	columns := int(bounds.W() / (size * 1.5))
+	for i := 0; i < count; i++ {
+		position := pixel.V(bounds.Min.X+(float64(i%columns)+0.5)*size*1.5,
+			bounds.Max.Y-size-float64(i/columns)*size*1.5)
+		switch i % 4 {
+		case 0:
+			world.Add(NewRigidCircle(position, size/2, 10))
+		case 1:
+			world.Add(NewRigidBox(position, size, size*0.6, 10))
+		case 2:
+			world.Add(NewRigidRegularPolygon(position, size/2, 3, 10))
+		case 3:
+			world.Add(NewRigidRegularPolygon(position, size/2, 5, 10))
+		}
+	}
+	return world
+}
+
+// newRigidRoom returns world with static floor and walls around bounds in pixels
+func newRigidRoom(bounds pixel.Rect) *RigidWorld {
+	const thickness = 100.0
+	world := &RigidWorld{gravity: Gravity}
+	world.Add(NewRigidBox(pixel.V(bounds.Center().X, bounds.Min.Y-thickness/2),
+		bounds.W()+2*thickness, thickness, 0))
+	world.Add(NewRigidBox(pixel.V(bounds.Min.X-thickness/2, bounds.Center().Y),
+		thickness, bounds.H(), 0))
+	world.Add(NewRigidBox(pixel.V(bounds.Max.X+thickness/2, bounds.Center().Y),
+		thickness, bounds.H(), 0))
+	return world
+}
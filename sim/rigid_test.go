@@ -0,0 +1,133 @@
+package sim
+
+import (
+	"math"
+	"testing"
+
+	"github.com/faiface/pixel"
+)
+
+// TestRmassProperties tests mass and moment of inertia of rigid bodies
+func TestRmassProperties(t *testing.T) {
+	// 2 m x 1 m box of density 3 kg/m^2
+	box := NewRigidBox(pixel.V(10, 20), 200, 100, 3)
+	if math.Abs(box.Mass-6) > 1e-9 {
+		t.Errorf("Box: Expected mass of %f got %f", 6.0, box.Mass)
+	}
+	if eInertia := 6 * (4 + 1) / 12.0; math.Abs(box.inertia-eInertia) > 1e-9 {
+		t.Errorf("Box: Expected inertia of %f got %f", eInertia, box.inertia)
+	}
+
+	// centre of mass of a right triangle is at third of it's legs
+	triangle := NewRigidPolygon(pixel.ZV,
+		[]pixel.Vec{pixel.V(0, 0), pixel.V(300, 0), pixel.V(0, 300)}, 1)
+	if triangle.Position.To(pixel.V(100, 100)).Len() > 1e-9 {
+		t.Errorf("Triangle: Expected centre of mass at %v got %v", pixel.V(100, 100),
+			triangle.Position)
+	}
+	// I = m * (a^2 + b^2) / 18 for right triangle with legs a and b around the centre of mass
+	if eInertia := 4.5 * (9 + 9) / 18; math.Abs(triangle.inertia-eInertia) > 1e-9 {
+		t.Errorf("Triangle: Expected inertia of %f got %f", eInertia, triangle.inertia)
+	}
+}
+
+// TestRresting tests that bodies dropped on the floor come to rest on it
+func TestRresting(t *testing.T) {
+	room := pixel.R(0, 0, 1000, 1000)
+	world := newRigidRoom(room)
+	box := world.Add(NewRigidBox(pixel.V(300, 200), 60, 40, 10))
+	ball := world.Add(NewRigidCircle(pixel.V(600, 300), 25, 10))
+	world.SetMaterial(0.5, 0.2)
+
+	for i := 0; i < 300; i++ {
+		world.Update(1.0 / 100)
+	}
+
+	for _, test := range []struct {
+		body    *RigidBody
+		eHeight float64
+	}{
+		{box, 20},
+		{ball, 25},
+	} {
+		if math.Abs(test.body.Position.Y-test.eHeight) > 1 || test.body.Speed.Len() > 1e-2 {
+			t.Errorf("Resting body: Expected height of %f at rest got %f moving at %v",
+				test.eHeight, test.body.Position.Y, test.body.Speed)
+		}
+	}
+	if math.Abs(math.Remainder(box.Angle, math.Pi/2)) > 1e-2 {
+		t.Errorf("Resting box: Expected to lie on it's side got angle %f", box.Angle)
+	}
+}
+
+// TestRstack tests that pyramid of boxes stands
+func TestRstack(t *testing.T) {
+	world := NewRigidStack(pixel.R(0, 0, 1000, 1000), 4, 40)
+	world.SetMaterial(0.6, 0)
+
+	initial := make([]pixel.Vec, len(world.bodies))
+	for i, body := range world.bodies {
+		initial[i] = body.Position
+	}
+
+	for i := 0; i < 500; i++ {
+		world.Update(1.0 / 100)
+	}
+
+	for i, body := range world.bodies {
+		if d := initial[i].To(body.Position).Len(); d > 4 {
+			t.Errorf("Stack: Expected box %d to stay at %v got %v", i, initial[i], body.Position)
+		}
+	}
+}
+
+// TestRfriction tests sliding distance of a box decelerated by Coulomb friction
+func TestRfriction(t *testing.T) {
+	const (
+		friction = 0.4
+		speed    = 3.0
+	)
+
+	world := newRigidRoom(pixel.R(-1e4, 0, 1e4, 1000))
+	box := world.Add(NewRigidBox(pixel.V(0, 20), 40, 40, 10))
+	world.SetMaterial(friction, 0)
+	box.Speed = pixel.V(speed, 0)
+
+	for i := 0; i < 300; i++ {
+		world.Update(1.0 / 200)
+	}
+
+	// d = v^2 / (2 * μ * g)
+	eDistance := speed * speed / (2 * friction * math.Abs(Gravity.Y))
+	distance := box.Position.X / PixelsPerMeter
+	if math.Abs(distance-eDistance) > 0.05*eDistance {
+		t.Errorf("Friction: Expected sliding distance of %f got %f", eDistance, distance)
+	}
+}
+
+// TestRparticles tests that particles push bodies and momentum is conserved
+func TestRparticles(t *testing.T) {
+	world := &RigidWorld{}
+	box := world.Add(NewRigidBox(pixel.V(100, 0), 40, 40, 10))
+	world.SetMaterial(0, 0.5)
+
+	particles := NewParticles(0, nil)
+	particles.Add(Particle{
+		Position: pixel.V(79, 0),
+		Speed:    pixel.V(5, 0),
+		Mass:     0.5,
+		Radius:   ParticleRadius,
+	})
+
+	eMomentum := momentum(particles.slice())
+	world.CollideParticles(particles, 0.01)
+	total := momentum(particles.slice()).Add(box.Speed.Scaled(box.Mass))
+
+	if total.To(eMomentum).Len() > 1e-9 {
+		t.Errorf("Particle impact: Expected momentum of %v got %v", eMomentum, total)
+	}
+	if box.Speed.X <= 0 || math.Abs(box.AngularSpeed) > 1e-9 {
+		t.Errorf("Particle impact: Expected box pushed forward got speed %v angular speed %f",
+			box.Speed, box.AngularSpeed)
+	}
+}
@@ -0,0 +1,187 @@
+package sim
+
+import (
+	"math"
+	"sync/atomic"
+
+	"github.com/faiface/pixel"
+)
+
+// Butcher tableau of the Dormand-Prince method. The last row of dormandPrinceA is equal to the
+// fifth order solution weights, dormandPrinceE are differences between fifth and fourth order
+// solution weights used for the error estimation
+var (
+	dormandPrinceA = [7][6]float64{
+		{},
+		{1.0 / 5.0},
+		{3.0 / 40.0, 9.0 / 40.0},
+		{44.0 / 45.0, -56.0 / 15.0, 32.0 / 9.0},
+		{19372.0 / 6561.0, -25360.0 / 2187.0, 64448.0 / 6561.0, -212.0 / 729.0},
+		{9017.0 / 3168.0, -355.0 / 33.0, 46732.0 / 5247.0, 49.0 / 176.0, -5103.0 / 18656.0},
+		{35.0 / 384.0, 0, 500.0 / 1113.0, 125.0 / 192.0, -2187.0 / 6784.0, 11.0 / 84.0},
+	}
+	dormandPrinceE = [7]float64{
+		71.0 / 57600.0, 0, -71.0 / 16695.0, 71.0 / 1920.0, -17253.0 / 339200.0, 22.0 / 525.0,
+		-1.0 / 40.0,
+	}
+)
+
+const (
+	// maximal number of substeps per one call of Step, after which the rest of the time-step is
+	// taken regardless of the error
+	dormandPrinceMaxSubsteps = 1000
+	// minimal substep in s which is always accepted
+	dormandPrinceMinStep = 1e-9
+)
+
+// DormandPrinceIntegrator calculates new position of a particle based on embedded Runge-Kutta 4(5)
+// method of Dormand and Prince. The time-step is split into substeps which are chosen per particle
+// so the estimated local error stays below the given tolerances
+type DormandPrinceIntegrator struct {
+	absTolerance float64 // in m and m*s^{-1}
+	relTolerance float64
+	accepted     int64 // number of accepted substeps since the last call of StepCount, atomic
+	rejected     int64 // number of rejected substeps since the last call of StepCount, atomic
+}
+
+// StepCounter is implemented by adaptive integrators which report number of accepted and rejected
+// substeps
+type StepCounter interface {
+	// StepCount returns number of accepted and rejected substeps since the last call
+	StepCount() (accepted int, rejected int)
+}
+
+func init() {
+	RegisterIntegrator(&DormandPrinceIntegrator{
+		absTolerance: 1e-4,
+		relTolerance: 1e-4,
+	})
+}
+
+// Name returns name of the integration method
+func (integrator *DormandPrinceIntegrator) Name() string {
+	return "RK45"
+}
+
+// Order returns order of accuracy of the integration method
+func (integrator *DormandPrinceIntegrator) Order() int {
+	return 5
+}
+
+// StepCount returns number of accepted and rejected substeps since the last call
+func (integrator *DormandPrinceIntegrator) StepCount() (int, int) {
+	accepted := atomic.SwapInt64(&integrator.accepted, 0)
+	rejected := atomic.SwapInt64(&integrator.rejected, 0)
+	return int(accepted), int(rejected)
+}
+
+// addStepCount adds accepted and rejected substeps to the counts reported by StepCount
+func (integrator *DormandPrinceIntegrator) addStepCount(accepted int, rejected int) {
+	if accepted > 0 {
+		atomic.AddInt64(&integrator.accepted, int64(accepted))
+	}
+	if rejected > 0 {
+		atomic.AddInt64(&integrator.rejected, int64(rejected))
+	}
+}
+
+// Step calculates new position of a particle using adaptive Dormand-Prince method
+func (integrator *DormandPrinceIntegrator) Step(p *Particle, dt float64) pixel.Vec {
+	h := p.stepSize
+	if h <= 0 || h > dt {
+		h = dt
+	}
+
+	// substeps are counted locally, so workers touch the shared counters once per particle
+	var accepted, rejected int
+	position, speed := p.Position, p.Speed
+	for t, substeps := 0.0, 0; t < dt; substeps++ {
+		last := false
+		if t+h >= dt || substeps == dormandPrinceMaxSubsteps {
+			h = dt - t
+			last = true
+		}
+
+		newPosition, newSpeed, errPosition, errSpeed := p.dormandPrinceStep(position, speed, h)
+
+		// error is measured in SI units so position and speed tolerances are comparable
+		err := math.Max(
+			integrator.errorRatio(
+				errPosition.Scaled(1/PixelsPerMeter),
+				position.Scaled(1/PixelsPerMeter),
+				newPosition.Scaled(1/PixelsPerMeter),
+			),
+			integrator.errorRatio(errSpeed, speed, newSpeed),
+		)
+
+		if err <= 1 || h <= dormandPrinceMinStep || substeps == dormandPrinceMaxSubsteps {
+			accepted++
+			position, speed = newPosition, newSpeed
+			t += h
+			if last {
+				t = dt
+			}
+		} else {
+			rejected++
+		}
+
+		// h_{new} = h * 0.9 * err^{-1/5} where the change is limited to the interval [0.2, 5]
+		factor := 5.0
+		if err > 0 {
+			factor = math.Min(5, math.Max(0.2, 0.9*math.Pow(err, -1.0/5.0)))
+		}
+		// step shortened to fit the end of the time-step is not used as suggestion for the next one
+		if !last || err > 1 {
+			p.stepSize = h * factor
+		}
+		h *= factor
+	}
+
+	integrator.addStepCount(accepted, rejected)
+
+	p.Speed = speed
+	return position
+}
+
+// errorRatio returns ratio of the error estimate and the tolerance for a two dimensional quantity
+// with values before and after the substep
+func (integrator *DormandPrinceIntegrator) errorRatio(err, before, after pixel.Vec) float64 {
+	scale := func(a, b float64) float64 {
+		return integrator.absTolerance + integrator.relTolerance*math.Max(math.Abs(a), math.Abs(b))
+	}
+	return math.Max(
+		math.Abs(err.X)/scale(before.X, after.X),
+		math.Abs(err.Y)/scale(before.Y, after.Y),
+	)
+}
+
+// dormandPrinceStep calculates one substep of length h of Dormand-Prince method and returns fifth
+// order solution together with the local error estimate
+func (p *Particle) dormandPrinceStep(
+	position pixel.Vec,
+	speed pixel.Vec,
+	h float64) (pixel.Vec, pixel.Vec, pixel.Vec, pixel.Vec) {
+	var kx, kv [7]pixel.Vec
+
+	for i := 0; i < 7; i++ {
+		x, v := position, speed
+		for j := 0; j < i; j++ {
+			x = x.Add(kx[j].Scaled(h * dormandPrinceA[i][j]))
+			v = v.Add(kv[j].Scaled(h * dormandPrinceA[i][j]))
+		}
+		// the last stage is evaluated at the fifth order solution
+		if i == 6 {
+			position, speed = x, v
+		}
+		kx[i] = v.Scaled(PixelsPerMeter)
+		kv[i] = p.acceleration(x, v)
+	}
+
+	var errPosition, errSpeed pixel.Vec
+	for i := 0; i < 7; i++ {
+		errPosition = errPosition.Add(kx[i].Scaled(h * dormandPrinceE[i]))
+		errSpeed = errSpeed.Add(kv[i].Scaled(h * dormandPrinceE[i]))
+	}
+
+	return position, speed, errPosition, errSpeed
+}
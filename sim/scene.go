@@ -0,0 +1,209 @@
+package sim
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/faiface/pixel"
+)
+
+// sceneCellSize is size of cells of the broadphase of scenes in pixels
+const sceneCellSize = 64.0
+
+// sceneMargin is distance in pixels by which bounds of colliders are enlarged in the broadphase,
+// so particles touching a collider by their radius find it in the cell of their centre
+const sceneMargin = 16.0
+
+// ColliderShape is enum for choosing shape of colliders a scene is built from
+type ColliderShape int
+
+const (
+	// CircleShape is a circle
+	CircleShape ColliderShape = iota
+	// PolygonShape is a convex trapezoid
+	PolygonShape ColliderShape = iota
+	// SegmentShape is a tilted line segment
+	SegmentShape ColliderShape = iota
+	// BoxShape is an axis-aligned box
+	BoxShape ColliderShape = iota
+	// OrientedBoxShape is a box rotated by 30 degrees
+	OrientedBoxShape ColliderShape = iota
+	// CapsuleShape is a horizontal capsule
+	CapsuleShape ColliderShape = iota
+)
+
+// NewCollider returns collider of a given shape centred at position whose bounding box is about
+// size pixels wide
+func NewCollider(shape ColliderShape, position pixel.Vec, size float64) Collider {
+	s := size / 2
+	switch shape {
+	case PolygonShape:
+		return &ConvexPolygon{Vertices: []pixel.Vec{
+			position.Add(pixel.V(-s, -s/2)),
+			position.Add(pixel.V(s, -s/2)),
+			position.Add(pixel.V(s*0.6, s/2)),
+			position.Add(pixel.V(-s*0.6, s/2)),
+		}}
+	case SegmentShape:
+		return &Segment{A: position.Add(pixel.V(-s, -s/4)), B: position.Add(pixel.V(s, s/4))}
+	case BoxShape:
+		return &AxisAlignedBox{
+			Min: position.Add(pixel.V(-s, -s/2)),
+			Max: position.Add(pixel.V(s, s/2)),
+		}
+	case OrientedBoxShape:
+		return &OrientedBox{Center: position, HalfSize: pixel.V(s, s*0.4), Angle: math.Pi / 6}
+	case CapsuleShape:
+		return &Capsule{A: position.Add(pixel.V(-s*0.6, 0)), B: position.Add(pixel.V(s*0.6, 0)),
+			Radius: s * 0.4}
+	default:
+		return &Circle{Position: position, Radius: s}
+	}
+}
+
+// Scene represents collection of colliders. Broadphase sorts colliders into a grid by their
+// bounds, so each particle is tested only against colliders of the cell it is in. Colliders have
+// to be moved by Move of the scene so the grid is rebuilt
+type Scene struct {
+	colliders []Collider
+	hash      *SpatialHash
+	dirty     bool // the grid is rebuilt before the next query
+}
+
+// NewScene creates scene with given colliders
+func NewScene(colliders ...Collider) *Scene {
+	return &Scene{
+		colliders: colliders,
+		hash:      NewSpatialHash(sceneCellSize),
+		dirty:     true,
+	}
+}
+
+// Add adds collider to the scene
+func (scene *Scene) Add(collider Collider) {
+	scene.colliders = append(scene.colliders, collider)
+	scene.dirty = true
+}
+
+// Remove removes collider from the scene
+func (scene *Scene) Remove(collider Collider) {
+	for i := range scene.colliders {
+		if scene.colliders[i] == collider {
+			scene.colliders = append(scene.colliders[:i], scene.colliders[i+1:]...)
+			scene.dirty = true
+			return
+		}
+	}
+}
+
+// Clear removes all colliders from the scene
+func (scene *Scene) Clear() {
+	scene.colliders = scene.colliders[:0]
+	scene.dirty = true
+}
+
+// Colliders returns all colliders of the scene
+func (scene *Scene) Colliders() []Collider {
+	return scene.colliders
+}
+
+// Move moves collider of the scene by delta
+func (scene *Scene) Move(collider Collider, delta pixel.Vec) {
+	collider.Move(delta)
+	scene.dirty = true
+}
+
+// ColliderAt returns collider whose inside or boundary is within distance in pixels from
+// position, the last one is returned when more of them overlap
+func (scene *Scene) ColliderAt(position pixel.Vec, distance float64) Collider {
+	for i := len(scene.colliders) - 1; i >= 0; i-- {
+		if scene.colliders[i].Contains(position) ||
+			scene.colliders[i].ClosestPoint(position).To(position).Len() <= distance {
+			return scene.colliders[i]
+		}
+	}
+	return nil
+}
+
+// Near calls visit with every collider which may be closer than radius in pixels to position.
+// Colliders further away may be visited too
+func (scene *Scene) Near(position pixel.Vec, radius float64, visit func(collider Collider)) {
+	// the grid only finds colliders within the margin, larger particles test all of them
+	if radius > sceneMargin {
+		for _, collider := range scene.colliders {
+			visit(collider)
+		}
+		return
+	}
+
+	if scene.dirty {
+		scene.build()
+	}
+	scene.hash.Cell(position, func(index int) {
+		visit(scene.colliders[index])
+	})
+}
+
+// Sweep returns the first collider hit by circle with radius in pixels moving from-to and
+// fraction of the path at which it hits, nil is returned when nothing is hit
+func (scene *Scene) Sweep(from pixel.Vec, to pixel.Vec, radius float64) (float64, Collider) {
+	earliest, hit := 1.0, Collider(nil)
+	sweep := func(collider Collider) {
+		if t, ok := timeOfImpact(collider, from, to, radius); ok && (hit == nil || t < earliest) {
+			earliest, hit = t, collider
+		}
+	}
+
+	if radius > sceneMargin {
+		for _, collider := range scene.colliders {
+			sweep(collider)
+		}
+		return earliest, hit
+	}
+
+	if scene.dirty {
+		scene.build()
+	}
+	scene.hash.Cells(pointsBounds(from, to), func(index int) {
+		sweep(scene.colliders[index])
+	})
+	return earliest, hit
+}
+
+// build sorts colliders into cells of the grid
+func (scene *Scene) build() {
+	scene.hash.Clear(sceneCellSize)
+	for i, collider := range scene.colliders {
+		bounds := collider.Bounds()
+		scene.hash.InsertBounds(i, pixel.R(bounds.Min.X-sceneMargin, bounds.Min.Y-sceneMargin,
+			bounds.Max.X+sceneMargin, bounds.Max.Y+sceneMargin))
+	}
+	scene.dirty = false
+}
+
+// NewPegScene returns scene with staggered rows of small colliders of a given shape filling
+// bounds in pixels like pegs of a Galton board
+func NewPegScene(bounds pixel.Rect, shape ColliderShape) *Scene {
+	const spacing = 60.0
+	scene := NewScene()
+	for row := 0; float64(row)*spacing < bounds.H(); row++ {
+		shift := float64(row%2) * spacing / 2
+		for x := bounds.Min.X + spacing/2 + shift; x < bounds.Max.X; x += spacing {
+			scene.Add(NewCollider(shape, pixel.V(x, bounds.Min.Y+float64(row)*spacing), 16))
+		}
+	}
+	return scene
+}
+
+// NewScatterScene returns scene with count colliders of random shapes and sizes scattered over
+// bounds in pixels. Shapes, sizes and positions are drawn from random
+func NewScatterScene(bounds pixel.Rect, count int, random *rand.Rand) *Scene {
+	scene := NewScene()
+	for i := 0; i < count; i++ {
+		position := pixel.V(bounds.Min.X+random.Float64()*bounds.W(),
+			bounds.Min.Y+random.Float64()*bounds.H())
+		scene.Add(NewCollider(ColliderShape(random.Intn(int(CapsuleShape)+1)), position,
+			20+random.Float64()*40))
+	}
+	return scene
+}
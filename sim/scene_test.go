@@ -0,0 +1,52 @@
+package sim
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/faiface/pixel"
+)
+
+// TestBbroadphase tests the broadphase of a scene finds every collider touched by a particle
+func TestBbroadphase(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	scene := NewScatterScene(pixel.R(0, 0, 1000, 1000), 100, random)
+
+	for i := 0; i < 10000; i++ {
+		position := pixel.V(random.Float64()*1000, random.Float64()*1000)
+		radius := random.Float64() * 2 * sceneMargin
+
+		visited := make(map[Collider]bool)
+		scene.Near(position, radius, func(collider Collider) {
+			visited[collider] = true
+		})
+
+		for _, collider := range scene.Colliders() {
+			touching := collider.Contains(position) ||
+				collider.ClosestPoint(position).To(position).Len() < radius
+			if touching && !visited[collider] {
+				t.Fatalf("Scene: Expected collider at %v touching particle at %v with radius %f",
+					collider.Bounds(), position, radius)
+			}
+		}
+	}
+}
+
+// TestBmove tests colliders moved by the scene are found at their new position
+func TestBmove(t *testing.T) {
+	circle := &Circle{Position: pixel.V(0, 0), Radius: 10}
+	scene := NewScene(circle)
+	scene.Near(pixel.V(0, 0), 1, func(Collider) {})
+
+	scene.Move(circle, pixel.V(500, 0))
+	found := false
+	scene.Near(pixel.V(500, 0), 1, func(collider Collider) {
+		found = found || collider == circle
+	})
+	if !found {
+		t.Errorf("Scene: Expected moved circle near %v", circle.Position)
+	}
+	if scene.ColliderAt(pixel.V(505, 0), 0) != circle {
+		t.Errorf("Scene: Expected moved circle at %v", pixel.V(505, 0))
+	}
+}
@@ -0,0 +1,104 @@
+package sim
+
+import (
+	"math"
+
+	"github.com/faiface/pixel"
+)
+
+// SpatialHash represents uniform grid of square cells which is used to find pairs of nearby
+// objects without testing all pairs
+type SpatialHash struct {
+	cellSize float64         // in pixels
+	cells    map[int64][]int // indices of objects in each cell
+	used     []int64         // keys of cells that contain any object
+}
+
+// NewSpatialHash creates a new spatial hash with a given size of cells in pixels
+func NewSpatialHash(cellSize float64) *SpatialHash {
+	return &SpatialHash{
+		cellSize: cellSize,
+		cells:    make(map[int64][]int),
+	}
+}
+
+// Clear removes all objects from the spatial hash and changes the size of it's cells. Memory of
+// the cells is kept for the next use
+func (hash *SpatialHash) Clear(cellSize float64) {
+	for _, key := range hash.used {
+		hash.cells[key] = hash.cells[key][:0]
+	}
+	hash.used = hash.used[:0]
+
+	// cells of the previous size are dropped so the map does not grow over time
+	if cellSize != hash.cellSize {
+		hash.cells = make(map[int64][]int)
+		hash.cellSize = cellSize
+	}
+}
+
+// Insert adds object with a given index to the cell containing position
+func (hash *SpatialHash) Insert(index int, position pixel.Vec) {
+	key := hash.key(hash.cell(position))
+	if len(hash.cells[key]) == 0 {
+		hash.used = append(hash.used, key)
+	}
+	hash.cells[key] = append(hash.cells[key], index)
+}
+
+// InsertBounds adds object with a given index to every cell overlapping bounds
+func (hash *SpatialHash) InsertBounds(index int, bounds pixel.Rect) {
+	minX, minY := hash.cell(bounds.Min)
+	maxX, maxY := hash.cell(bounds.Max)
+	for x := minX; x <= maxX; x++ {
+		for y := minY; y <= maxY; y++ {
+			key := hash.key(x, y)
+			if len(hash.cells[key]) == 0 {
+				hash.used = append(hash.used, key)
+			}
+			hash.cells[key] = append(hash.cells[key], index)
+		}
+	}
+}
+
+// Cell calls visit with the index of every object in the cell containing position
+func (hash *SpatialHash) Cell(position pixel.Vec, visit func(index int)) {
+	for _, index := range hash.cells[hash.key(hash.cell(position))] {
+		visit(index)
+	}
+}
+
+// Cells calls visit with the index of every object in cells overlapping bounds. Objects in more
+// of the cells are visited more times
+func (hash *SpatialHash) Cells(bounds pixel.Rect, visit func(index int)) {
+	minX, minY := hash.cell(bounds.Min)
+	maxX, maxY := hash.cell(bounds.Max)
+	for x := minX; x <= maxX; x++ {
+		for y := minY; y <= maxY; y++ {
+			for _, index := range hash.cells[hash.key(x, y)] {
+				visit(index)
+			}
+		}
+	}
+}
+
+// Neighbours calls visit with the index of every object in the cell containing position and in
+// the eight cells around it
+func (hash *SpatialHash) Neighbours(position pixel.Vec, visit func(index int)) {
+	x, y := hash.cell(position)
+	for dx := -1; dx <= 1; dx++ {
+		for dy := -1; dy <= 1; dy++ {
+			for _, index := range hash.cells[hash.key(x+dx, y+dy)] {
+				visit(index)
+			}
+		}
+	}
+}
+
+func (hash *SpatialHash) cell(position pixel.Vec) (int, int) {
+	return int(math.Floor(position.X / hash.cellSize)), int(math.Floor(position.Y / hash.cellSize))
+}
+
+func (hash *SpatialHash) key(x, y int) int64 {
+	return int64(x)<<32 | int64(uint32(y))
+}
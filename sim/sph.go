@@ -0,0 +1,180 @@
+package sim
+
+import (
+	"math"
+
+	"github.com/faiface/pixel"
+)
+
+// fluidFloorRestitution is coefficient of restitution of fluid particles hitting the floor
+const fluidFloorRestitution = 0.1
+
+// Fluid represents smoothed-particle hydrodynamics of particles of a particle system. Densities
+// and forces are calculated once per fixed physics step by Update and then act on particles as a
+// force during the step. The fluid is two-dimensional, so densities are measured in kg*m^{-2}
+type Fluid struct {
+	Enabled         bool
+	SmoothingLength *Parameter // radius of the kernels in m
+	RestDensity     *Parameter // in kg*m^{-2}
+	Stiffness       *Parameter // gas constant of the equation of state in m^2*s^{-2}
+	Viscosity       *Parameter // dynamic viscosity in kg*s^{-1}
+	SurfaceTension  *Parameter // in N
+	hash            *SpatialHash
+	pressures       []float64
+}
+
+// poly6 returns poly6 smoothing kernel for squared distance r2 in m^2
+func poly6(r2 float64, h float64) float64 {
+	// W = 4/(π*h^8) * (h^2 - r^2)^3
+	if r2 >= h*h {
+		return 0
+	}
+	f := h*h - r2
+	return 4 / (math.Pi * math.Pow(h, 8)) * f * f * f
+}
+
+// poly6Gradient returns gradient of poly6 kernel for vector r in m
+func poly6Gradient(r pixel.Vec, h float64) pixel.Vec {
+	// ∇W = -24/(π*h^8) * (h^2 - r^2)^2 * r
+	r2 := r.Dot(r)
+	if r2 >= h*h {
+		return pixel.ZV
+	}
+	f := h*h - r2
+	return r.Scaled(-24 / (math.Pi * math.Pow(h, 8)) * f * f)
+}
+
+// poly6Laplacian returns laplacian of poly6 kernel for squared distance r2 in m^2
+func poly6Laplacian(r2 float64, h float64) float64 {
+	// ∇²W = -48/(π*h^8) * (h^2 - r^2) * (h^2 - 3*r^2)
+	if r2 >= h*h {
+		return 0
+	}
+	return -48 / (math.Pi * math.Pow(h, 8)) * (h*h - r2) * (h*h - 3*r2)
+}
+
+// spikyGradient returns gradient of spiky kernel for vector r in m which does not vanish for
+// close particles, so the pressure keeps them apart
+func spikyGradient(r pixel.Vec, h float64) pixel.Vec {
+	// W = 10/(π*h^5) * (h - r)^3, ∇W = -30/(π*h^5) * (h - r)^2 * r/|r|
+	length := r.Len()
+	if length >= h || length == 0 {
+		return pixel.ZV
+	}
+	return r.Scaled(-30 / (math.Pi * math.Pow(h, 5)) * (h - length) * (h - length) / length)
+}
+
+// viscosityLaplacian returns laplacian of viscosity kernel for distance r in m
+func viscosityLaplacian(r float64, h float64) float64 {
+	// ∇²W = 40/(π*h^5) * (h - r)
+	if r >= h {
+		return 0
+	}
+	return 40 / (math.Pi * math.Pow(h, 5)) * (h - r)
+}
+
+// Update calculates densities of particles and forces of pressure, viscosity and surface tension
+// acting on them. Neighbours are found by spatial hash with cells of the size of the kernels
+func (fluid *Fluid) Update(particles *Particles) {
+	n := particles.Len()
+	if !fluid.Enabled || n == 0 {
+		return
+	}
+
+	position, speed, mass := particles.position, particles.speed, particles.mass
+	density := particles.density
+
+	h := fluid.SmoothingLength.Value
+	if fluid.hash == nil {
+		fluid.hash = NewSpatialHash(h * PixelsPerMeter)
+	}
+	fluid.hash.Clear(h * PixelsPerMeter)
+	for i := range position {
+		fluid.hash.Insert(i, position[i])
+	}
+
+	// ρ_i = Σ m_j * W(r_ij), p_i = k * (ρ_i - ρ_0) clamped to zero so the fluid does not clump
+	if cap(fluid.pressures) < n {
+		fluid.pressures = make([]float64, n)
+	}
+	fluid.pressures = fluid.pressures[:n]
+	for i := range position {
+		density[i] = 0
+		fluid.hash.Neighbours(position[i], func(j int) {
+			r := position[i].To(position[j]).Scaled(1 / PixelsPerMeter)
+			density[i] += mass[j] * poly6(r.Dot(r), h)
+		})
+		fluid.pressures[i] = math.Max(0, fluid.Stiffness.Value*(density[i]-fluid.RestDensity.Value))
+	}
+
+	for i := range position {
+		pressure, viscosity := pixel.ZV, pixel.ZV
+		normal, curvature := pixel.ZV, 0.0
+		fluid.hash.Neighbours(position[i], func(j int) {
+			r := position[j].To(position[i]).Scaled(1 / PixelsPerMeter)
+			r2 := r.Dot(r)
+			if r2 >= h*h {
+				return
+			}
+
+			// colour field of the fluid used by surface tension
+			curvature += mass[j] / density[j] * poly6Laplacian(r2, h)
+			if j == i {
+				return
+			}
+			normal = normal.Add(poly6Gradient(r, h).Scaled(mass[j] / density[j]))
+
+			// symmetric forms conserve momentum
+			// F_i = -m_i * Σ m_j * (p_i/ρ_i^2 + p_j/ρ_j^2) * ∇W(r_ij)
+			pressure = pressure.Sub(spikyGradient(r, h).Scaled(mass[j] *
+				(fluid.pressures[i]/(density[i]*density[i]) + fluid.pressures[j]/(density[j]*density[j]))))
+			// F_i = μ * m_i * Σ m_j * (v_j - v_i) / (ρ_i * ρ_j) * ∇²W(r_ij)
+			viscosity = viscosity.Add(speed[j].Sub(speed[i]).Scaled(mass[j] / (density[i] * density[j]) *
+				viscosityLaplacian(math.Sqrt(r2), h)))
+		})
+
+		force := pressure.Add(viscosity.Scaled(fluid.Viscosity.Value)).Scaled(mass[i])
+
+		// F_i = -σ * ∇²c * n/|n| * m_i/ρ_i is applied only at the surface where normal is long
+		if normal.Len() > 0.1/h {
+			force = force.Add(normal.Unit().Scaled(
+				-fluid.SurfaceTension.Value * curvature * mass[i] / density[i]))
+		}
+		particles.fluidForce[i] = force
+	}
+}
+
+// Force returns force of the fluid acting on the particle in N calculated by the last Update
+func (fluid *Fluid) Force(p *Particle, position pixel.Vec, speed pixel.Vec) pixel.Vec {
+	if !fluid.Enabled {
+		return pixel.ZV
+	}
+	return p.fluidForce
+}
+
+// Jacobian returns partial derivatives of the force of the fluid which are zero as the force is
+// constant during the step
+func (fluid *Fluid) Jacobian(p *Particle, position pixel.Vec, speed pixel.Vec) (Matrix2, Matrix2) {
+	return Matrix2{}, Matrix2{}
+}
+
+// Resolve keeps fluid particles above the floor given in pixels, so they pool at the bottom of
+// the window
+func (fluid *Fluid) Resolve(particles *Particles, floor float64, dt float64) {
+	if !fluid.Enabled {
+		return
+	}
+
+	for i := range particles.position {
+		bottom := floor + particles.radius[i]*PixelsPerMeter
+		if particles.position[i].Y >= bottom {
+			continue
+		}
+
+		particles.position[i].Y = bottom
+		if particles.speed[i].Y < 0 {
+			particles.speed[i].Y = -particles.speed[i].Y * fluidFloorRestitution
+		}
+		particles.resetHistory(i, dt)
+	}
+}
@@ -0,0 +1,99 @@
+package sim
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/faiface/pixel"
+)
+
+// newTestFluid returns enabled fluid with parameters used by the window loop
+func newTestFluid(surfaceTension float64) *Fluid {
+	return &Fluid{
+		Enabled:         true,
+		SmoothingLength: &Parameter{Value: 0.1},
+		RestDensity:     &Parameter{Value: 50},
+		Stiffness:       &Parameter{Value: 50},
+		Viscosity:       &Parameter{Value: 0.5},
+		SurfaceTension:  &Parameter{Value: surfaceTension},
+	}
+}
+
+// TestSkernels tests that smoothing kernels integrate to one over the plane
+func TestSkernels(t *testing.T) {
+	const (
+		h     = 0.1
+		steps = 400
+	)
+
+	poly6Integral, spikyIntegral := 0.0, 0.0
+	for i := 0; i < steps; i++ {
+		r := (float64(i) + 0.5) * h / steps
+		ring := 2 * math.Pi * r * h / steps
+		poly6Integral += poly6(r*r, h) * ring
+		// spiky kernel is recovered from it's gradient, W(r) = 10/(π*h^5) * (h - r)^3
+		spikyIntegral += spikyGradient(pixel.V(r, 0), h).X / (-3 / (h - r)) * ring
+	}
+
+	for name, integral := range map[string]float64{"poly6": poly6Integral, "spiky": spikyIntegral} {
+		if math.Abs(integral-1) > 1e-3 {
+			t.Errorf("Kernel %s: Expected integral of %f got %f", name, 1.0, integral)
+		}
+	}
+}
+
+// TestSmomentum tests that pressure and viscosity of the fluid do not change total momentum
+func TestSmomentum(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	particles := NewParticles(0, nil)
+	for i := 0; i < 300; i++ {
+		particles.Add(Particle{
+			Position: pixel.V(random.Float64()*60, random.Float64()*60),
+			Speed:    pixel.V(random.NormFloat64(), random.NormFloat64()),
+			Mass:     0.05,
+			Radius:   ParticleRadius,
+		})
+	}
+
+	newTestFluid(0).Update(particles)
+
+	total := pixel.ZV
+	for _, force := range particles.fluidForce {
+		total = total.Add(force)
+	}
+	if total.Len() > 1e-9 {
+		t.Errorf("Fluid: Expected total force of %f got %f", pixel.ZV, total)
+	}
+}
+
+// TestSpool tests that a block of fluid falling on the floor spreads over it without exploding
+func TestSpool(t *testing.T) {
+	fluid := newTestFluid(0.05)
+	forces := ForceField{UniformGravity{Acceleration: Gravity}, fluid}
+
+	particles := NewParticles(0, &forces)
+	for x := 0; x < 10; x++ {
+		for y := 0; y < 10; y++ {
+			particles.Add(Particle{
+				Position: pixel.V(float64(x)*3, 20+float64(y)*3),
+				Mass:     0.05,
+				Radius:   ParticleRadius,
+			})
+		}
+	}
+
+	const dt = 0.002
+	for step := 0; step < 1500; step++ {
+		fluid.Update(particles)
+		integrate(particles, ExplicitEulerIntegrator{}, dt)
+		fluid.Resolve(particles, 0, dt)
+	}
+
+	for _, p := range particles.slice() {
+		if math.IsNaN(p.Position.Y) || p.Position.Y < 0 || p.Speed.Len() > 5 {
+			t.Fatalf("Fluid pool: Expected slow particle above floor got position %v speed %v",
+				p.Position, p.Speed)
+		}
+	}
+}
@@ -0,0 +1,287 @@
+package sim
+
+import "github.com/faiface/pixel"
+
+// Link represents spring with a damper connecting two particles of a mass-spring system
+type Link struct {
+	A, B       int     // indices of connected particles
+	restLength float64 // in m
+	stiffness  float64 // in N*m^{-1}
+	damping    float64 // in N*s*m^{-1}
+}
+
+// LinkSpring is force of a link acting on one of the connected particles. The other particle is
+// held in the state from the beginning of the step, so the result does not depend on the order in
+// which particles are integrated
+type LinkSpring struct {
+	other *Particle
+	link  *Link
+}
+
+// Force returns force of the link in N
+func (spring LinkSpring) Force(p *Particle, position pixel.Vec, speed pixel.Vec) pixel.Vec {
+	// F = -k * (|d| - l) * n - c * ((v - v_other) . n) * n where n = d/|d|
+	d := position.Sub(spring.other.Position).Scaled(1 / PixelsPerMeter)
+	length := d.Len()
+	if length == 0 {
+		return pixel.ZV
+	}
+
+	n := d.Scaled(1 / length)
+	relativeSpeed := speed.Sub(spring.other.Speed).Dot(n)
+	return n.Scaled(-spring.link.stiffness*(length-spring.link.restLength) -
+		spring.link.damping*relativeSpeed)
+}
+
+// Jacobian returns partial derivatives of the force of the link
+func (spring LinkSpring) Jacobian(
+	p *Particle,
+	position pixel.Vec,
+	speed pixel.Vec) (Matrix2, Matrix2) {
+	d := position.Sub(spring.other.Position).Scaled(1 / PixelsPerMeter)
+	length := d.Len()
+	if length == 0 {
+		return Identity2.Scaled(-spring.link.stiffness / PixelsPerMeter),
+			Identity2.Scaled(-spring.link.damping)
+	}
+
+	n := d.Scaled(1 / length)
+	nn := Outer2(n, n)
+	projection := Identity2.Sub(nn)
+	u := speed.Sub(spring.other.Speed)
+
+	// spring: dF/dd = -k * ((1 - l/|d|) * (I - n*n^T) + n*n^T)
+	dPosition := projection.Scaled(1 - spring.link.restLength/length).Add(nn).Scaled(
+		-spring.link.stiffness)
+	// damper: dF/dd = -c/|d| * (n*u^T*(I - n*n^T) + (u . n) * (I - n*n^T))
+	dPosition = dPosition.Add(Outer2(n, projection.Apply(u)).Add(projection.Scaled(u.Dot(n))).Scaled(
+		-spring.link.damping / length))
+
+	// position in the derivative is measured in pixels
+	return dPosition.Scaled(1 / PixelsPerMeter), nn.Scaled(-spring.link.damping)
+}
+
+// MassSpring represents system of particles connected by links. Pinned particles stay in place
+type MassSpring struct {
+	particles []Particle
+	links     []Link
+	pinned    []bool
+	Forces    *ForceField // external forces acting on all particles, DefaultForces when nil
+
+	previous   []Particle   // state of particles at the beginning of the step
+	fields     []ForceField // forces acting on each particle including its links
+	integrator Integrator   // integrator used in the last step
+}
+
+// AddParticle adds a particle at position in pixels with mass in kg and returns its index
+func (body *MassSpring) AddParticle(position pixel.Vec, mass float64) int {
+	body.particles = append(body.particles, Particle{
+		Position:     position,
+		lastPosition: position,
+		Mass:         mass,
+		Radius:       ParticleRadius,
+	})
+	body.pinned = append(body.pinned, false)
+	body.fields = nil
+	return len(body.particles) - 1
+}
+
+// Connect links particles a and b with a spring whose rest length is their current distance
+func (body *MassSpring) Connect(a int, b int, stiffness float64, damping float64) {
+	body.links = append(body.links, Link{
+		A:          a,
+		B:          b,
+		restLength: body.particles[a].Position.To(body.particles[b].Position).Len() / PixelsPerMeter,
+		stiffness:  stiffness,
+		damping:    damping,
+	})
+	body.fields = nil
+}
+
+// Particles returns particles of the body
+func (body *MassSpring) Particles() []Particle {
+	return body.particles
+}
+
+// Links returns links connecting particles of the body
+func (body *MassSpring) Links() []Link {
+	return body.links
+}
+
+// Pinned reports whether particle with the given index is fixed in place
+func (body *MassSpring) Pinned(index int) bool {
+	return body.pinned[index]
+}
+
+// Pin fixes particle with the given index in place
+func (body *MassSpring) Pin(index int) {
+	body.pinned[index] = true
+	body.particles[index].Speed = pixel.ZV
+}
+
+// SetStiffness sets stiffness in N*m^{-1} and damping in N*s*m^{-1} of all links
+func (body *MassSpring) SetStiffness(stiffness float64, damping float64) {
+	for i := range body.links {
+		body.links[i].stiffness = stiffness
+		body.links[i].damping = damping
+	}
+}
+
+// ParticleAt returns index of a particle close to the given position in pixels or -1
+func (body *MassSpring) ParticleAt(position pixel.Vec, distance float64) int {
+	for i := range body.particles {
+		if body.particles[i].Position.To(position).Len() <= distance {
+			return i
+		}
+	}
+	return -1
+}
+
+// build prepares force fields of particles after particles or links were added
+func (body *MassSpring) build() {
+	external := body.Forces
+	if external == nil {
+		external = &DefaultForces
+	}
+
+	body.previous = make([]Particle, len(body.particles))
+	body.fields = make([]ForceField, len(body.particles))
+	for i := range body.fields {
+		body.fields[i] = ForceField{external}
+	}
+	for i := range body.links {
+		link := &body.links[i]
+		body.fields[link.A] = append(body.fields[link.A],
+			LinkSpring{other: &body.previous[link.B], link: link})
+		body.fields[link.B] = append(body.fields[link.B],
+			LinkSpring{other: &body.previous[link.A], link: link})
+	}
+	for i := range body.particles {
+		body.particles[i].Forces = &body.fields[i]
+	}
+
+	body.integrator = nil
+}
+
+// Update advances all particles which are not pinned by time-step dt in s
+func (body *MassSpring) Update(dt float64, positionIntegrator Integrator, scene *Scene) {
+	if body.fields == nil {
+		body.build()
+	}
+
+	// history of positions is rebuilt whenever another integrator is chosen
+	if body.integrator != positionIntegrator {
+		for i := range body.particles {
+			body.particles[i].resetHistory(dt)
+		}
+		body.integrator = positionIntegrator
+	}
+
+	copy(body.previous, body.particles)
+
+	for i := range body.particles {
+		body.particles[i].lastPosition = body.particles[i].Position
+		if body.pinned[i] {
+			continue
+		}
+		newPosition := positionIntegrator.Step(&body.particles[i], dt)
+		body.particles[i].Position = collideWithColliders(&body.particles[i], newPosition, dt,
+			scene)
+	}
+}
+
+// Move moves particle with the given index to position in pixels and stops it
+func (body *MassSpring) Move(index int, position pixel.Vec, dt float64) {
+	body.particles[index].Position = position
+	body.particles[index].lastPosition = position
+	body.particles[index].Speed = pixel.ZV
+	if body.integrator != nil {
+		body.particles[index].resetHistory(dt)
+	}
+}
+
+// NewRope returns rope of segments links hanging from a pinned start point in pixels. The rope
+// starts stretched straight towards the end point, so it swings when released
+func NewRope(
+	start pixel.Vec,
+	end pixel.Vec,
+	segments int,
+	mass float64,
+	stiffness float64,
+	damping float64) *MassSpring {
+	body := &MassSpring{}
+	for i := 0; i <= segments; i++ {
+		position := start.Add(end.Sub(start).Scaled(float64(i) / float64(segments)))
+		body.AddParticle(position, mass/float64(segments+1))
+		if i > 0 {
+			body.Connect(i-1, i, stiffness, damping)
+		}
+	}
+	body.Pin(0)
+	return body
+}
+
+// NewChain returns chain of segments links with both ends pinned at points in pixels. The chain
+// is longer than the distance of its ends by slack and sags into a catenary
+func NewChain(
+	start pixel.Vec,
+	end pixel.Vec,
+	segments int,
+	slack float64,
+	mass float64,
+	stiffness float64,
+	damping float64) *MassSpring {
+	body := NewRope(start, end, segments, mass, stiffness, damping)
+	for i := range body.links {
+		body.links[i].restLength *= slack
+	}
+	body.Pin(segments)
+	return body
+}
+
+// NewCloth returns rectangular cloth of columns*rows particles with the given spacing in pixels
+// hanging from its top left corner at position. Particles are connected by structural and shear
+// links and every pinEvery-th particle of the top row is pinned together with both top corners
+func NewCloth(
+	position pixel.Vec,
+	columns int,
+	rows int,
+	spacing float64,
+	pinEvery int,
+	mass float64,
+	stiffness float64,
+	damping float64) *MassSpring {
+	body := &MassSpring{}
+	index := func(column, row int) int {
+		return row*columns + column
+	}
+
+	for row := 0; row < rows; row++ {
+		for column := 0; column < columns; column++ {
+			body.AddParticle(position.Add(pixel.V(float64(column)*spacing, -float64(row)*spacing)),
+				mass/float64(rows*columns))
+		}
+	}
+
+	for row := 0; row < rows; row++ {
+		for column := 0; column < columns; column++ {
+			if column > 0 {
+				body.Connect(index(column-1, row), index(column, row), stiffness, damping)
+			}
+			if row > 0 {
+				body.Connect(index(column, row-1), index(column, row), stiffness, damping)
+			}
+			if column > 0 && row > 0 {
+				body.Connect(index(column-1, row-1), index(column, row), stiffness, damping)
+				body.Connect(index(column, row-1), index(column-1, row), stiffness, damping)
+			}
+		}
+	}
+
+	for column := 0; column < columns; column++ {
+		if column == 0 || column == columns-1 || (pinEvery > 0 && column%pinEvery == 0) {
+			body.Pin(index(column, 0))
+		}
+	}
+	return body
+}
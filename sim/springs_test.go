@@ -0,0 +1,59 @@
+package sim
+
+import (
+	"math"
+	"testing"
+
+	"github.com/faiface/pixel"
+)
+
+// TestShangingMass tests that a mass hanging on a pinned link settles where the spring force
+// balances gravity for every integrator
+func TestShangingMass(t *testing.T) {
+	const (
+		mass      = 0.1
+		stiffness = 50.0
+		dt        = 0.002
+	)
+
+	for _, integrator := range Integrators() {
+		body := &MassSpring{}
+		body.AddParticle(pixel.V(0, 0), mass)
+		body.AddParticle(pixel.V(0, -50), mass)
+		body.Connect(0, 1, stiffness, 1)
+		body.Pin(0)
+
+		for i := 0; i < 5000; i++ {
+			body.Update(dt, integrator, NewScene())
+		}
+
+		// |d| = l + m*g/k
+		eLength := 0.5 + mass*math.Abs(Gravity.Y)/stiffness
+		length := body.particles[0].Position.To(body.particles[1].Position).Len() / PixelsPerMeter
+		if math.Abs(length-eLength) > 1e-3 {
+			t.Errorf("%s hanging mass: Expected length of %f got %f", integrator.Name(),
+				eLength, length)
+		}
+	}
+}
+
+// TestScloth tests links and pinned points of the cloth builder
+func TestScloth(t *testing.T) {
+	body := NewCloth(pixel.V(0, 0), 11, 4, 10, 5, 1, 100, 0.5)
+
+	// structural links of rows and columns and two shear links in every cell
+	eLinks := 10*4 + 11*3 + 2*10*3
+	if len(body.links) != eLinks {
+		t.Errorf("Cloth: Expected %d links got %d", eLinks, len(body.links))
+	}
+
+	pinned := 0
+	for _, p := range body.pinned {
+		if p {
+			pinned++
+		}
+	}
+	if pinned != 3 {
+		t.Errorf("Cloth: Expected %d pinned particles got %d", 3, pinned)
+	}
+}
@@ -0,0 +1,129 @@
+package sim
+
+import (
+	"math"
+
+	"github.com/faiface/pixel"
+)
+
+// Coefficients of the fourth order Yoshida integrator, where drift steps use yoshidaC and kick
+// steps use yoshidaD
+var (
+	yoshidaW0 = -math.Cbrt(2) / (2 - math.Cbrt(2))
+	yoshidaW1 = 1 / (2 - math.Cbrt(2))
+	yoshidaC  = [4]float64{
+		yoshidaW1 / 2, (yoshidaW0 + yoshidaW1) / 2, (yoshidaW0 + yoshidaW1) / 2, yoshidaW1 / 2,
+	}
+	yoshidaD = [3]float64{yoshidaW1, yoshidaW0, yoshidaW1}
+)
+
+// SymplecticEulerIntegrator calculates new position of a particle based on symplectic Euler method
+// which moves the particle with it's previous speed first and then updates the speed using the
+// force at the new position. ExplicitEulerIntegrator updates the speed first, which is the other
+// variant of symplectic Euler method
+type SymplecticEulerIntegrator struct{}
+
+// VelocityVerletIntegrator calculates new position of a particle based on velocity Verlet method
+// which keeps position and speed synchronised
+type VelocityVerletIntegrator struct{}
+
+// LeapfrogIntegrator calculates new position of a particle based on leapfrog method in the
+// drift-kick-drift form
+type LeapfrogIntegrator struct{}
+
+// YoshidaIntegrator calculates new position of a particle based on fourth order symplectic method
+// of Yoshida composed of three leapfrog steps
+type YoshidaIntegrator struct{}
+
+func init() {
+	RegisterIntegrator(SymplecticEulerIntegrator{})
+	RegisterIntegrator(VelocityVerletIntegrator{})
+	RegisterIntegrator(LeapfrogIntegrator{})
+	RegisterIntegrator(YoshidaIntegrator{})
+}
+
+// Name returns name of the integration method
+func (SymplecticEulerIntegrator) Name() string {
+	return "Symplectic Euler"
+}
+
+// Order returns order of accuracy of the integration method
+func (SymplecticEulerIntegrator) Order() int {
+	return 1
+}
+
+// Step calculates new position of a particle using symplectic Euler method
+func (SymplecticEulerIntegrator) Step(p *Particle, dt float64) pixel.Vec {
+	// p_{t+1} = p_{t} + h*v_{t}
+	position := p.Position.Add(p.Speed.Scaled(dt * PixelsPerMeter))
+	// v_{t+1} = v_{t} + h*a(p_{t+1})
+	p.Speed = p.Speed.Add(p.acceleration(position, p.Speed).Scaled(dt))
+
+	return position
+}
+
+// Name returns name of the integration method
+func (VelocityVerletIntegrator) Name() string {
+	return "Velocity Verlet"
+}
+
+// Order returns order of accuracy of the integration method
+func (VelocityVerletIntegrator) Order() int {
+	return 2
+}
+
+// Step calculates new position of a particle using velocity Verlet method
+func (VelocityVerletIntegrator) Step(p *Particle, dt float64) pixel.Vec {
+	// v_{t+(1/2)} = v_{t} + (h/2)*a(p_{t})
+	speed := p.Speed.Add(p.acceleration(p.Position, p.Speed).Scaled(dt / 2))
+	// p_{t+1} = p_{t} + h*v_{t+(1/2)}
+	position := p.Position.Add(speed.Scaled(dt * PixelsPerMeter))
+	// v_{t+1} = v_{t+(1/2)} + (h/2)*a(p_{t+1})
+	p.Speed = speed.Add(p.acceleration(position, speed).Scaled(dt / 2))
+
+	return position
+}
+
+// Name returns name of the integration method
+func (LeapfrogIntegrator) Name() string {
+	return "Leapfrog"
+}
+
+// Order returns order of accuracy of the integration method
+func (LeapfrogIntegrator) Order() int {
+	return 2
+}
+
+// Step calculates new position of a particle using leapfrog method
+func (LeapfrogIntegrator) Step(p *Particle, dt float64) pixel.Vec {
+	// p_{t+(1/2)} = p_{t} + (h/2)*v_{t}
+	position := p.Position.Add(p.Speed.Scaled(dt / 2 * PixelsPerMeter))
+	// v_{t+1} = v_{t} + h*a(p_{t+(1/2)})
+	p.Speed = p.Speed.Add(p.acceleration(position, p.Speed).Scaled(dt))
+	// p_{t+1} = p_{t+(1/2)} + (h/2)*v_{t+1}
+	return position.Add(p.Speed.Scaled(dt / 2 * PixelsPerMeter))
+}
+
+// Name returns name of the integration method
+func (YoshidaIntegrator) Name() string {
+	return "Yoshida"
+}
+
+// Order returns order of accuracy of the integration method
+func (YoshidaIntegrator) Order() int {
+	return 4
+}
+
+// Step calculates new position of a particle using fourth order Yoshida method
+func (YoshidaIntegrator) Step(p *Particle, dt float64) pixel.Vec {
+	// p_{i+1} = p_{i} + c_{i}*h*v_{i}
+	// v_{i+1} = v_{i} + d_{i}*h*a(p_{i+1})
+	position, speed := p.Position, p.Speed
+	for i := 0; i < len(yoshidaD); i++ {
+		position = position.Add(speed.Scaled(yoshidaC[i] * dt * PixelsPerMeter))
+		speed = speed.Add(p.acceleration(position, speed).Scaled(yoshidaD[i] * dt))
+	}
+	p.Speed = speed
+
+	return position.Add(speed.Scaled(yoshidaC[3] * dt * PixelsPerMeter))
+}
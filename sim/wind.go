@@ -0,0 +1,73 @@
+package sim
+
+import (
+	"math"
+
+	"github.com/faiface/pixel"
+)
+
+// Wind represents time-varying wind field composed of a steady wind of given direction and
+// turbulence given by curl noise. Particles are pulled towards the local speed of the wind
+type Wind struct {
+	Enabled   bool
+	Angle     *Parameter // direction of the steady wind in degrees
+	Speed     *Parameter // speed of the steady wind in m*s^{-1}
+	Strength  *Parameter // speed of the turbulence in m*s^{-1}
+	Scale     *Parameter // size of the turbulent eddies in m
+	Evolution *Parameter // rate of change of the turbulence in s^{-1}
+	Response  float64    // time in s in which particle adapts to the speed of the wind
+	time      float64    // in s
+}
+
+// Advance moves the turbulence of the wind forward in time
+func (wind *Wind) Advance(dt float64) {
+	wind.time += dt
+}
+
+// Reset moves the turbulence of the wind back to the start
+func (wind *Wind) Reset() {
+	wind.time = 0
+}
+
+// Velocity returns speed of the wind in m*s^{-1} at a given position in pixels
+func (wind *Wind) Velocity(position pixel.Vec) pixel.Vec {
+	if !wind.Enabled {
+		return pixel.ZV
+	}
+
+	steady := pixel.V(wind.Speed.Value, 0).Rotated(wind.Angle.Value * math.Pi / 180)
+	if wind.Scale.Value <= 0 {
+		return steady
+	}
+
+	x, y := position.Scaled(1 / (PixelsPerMeter * wind.Scale.Value)).XY()
+	cx, cy := curlNoise(x, y, wind.time*wind.Evolution.Value)
+
+	return steady.Add(pixel.V(cx, cy).Scaled(wind.Strength.Value))
+}
+
+// Force returns force of the wind in N
+func (wind *Wind) Force(p *Particle, position pixel.Vec, speed pixel.Vec) pixel.Vec {
+	if !wind.Enabled {
+		return pixel.ZV
+	}
+	// F = m*(w - v)/τ
+	return wind.Velocity(position).Sub(speed).Scaled(p.Mass / wind.Response)
+}
+
+// Jacobian returns partial derivatives of the force of the wind
+func (wind *Wind) Jacobian(p *Particle, position pixel.Vec, speed pixel.Vec) (Matrix2, Matrix2) {
+	if !wind.Enabled {
+		return Matrix2{}, Matrix2{}
+	}
+
+	// dF/dp = (m/τ)*dw/dp is approximated by central differences over 1 pixel
+	dx := wind.Velocity(position.Add(pixel.V(0.5, 0))).Sub(
+		wind.Velocity(position.Sub(pixel.V(0.5, 0))))
+	dy := wind.Velocity(position.Add(pixel.V(0, 0.5))).Sub(
+		wind.Velocity(position.Sub(pixel.V(0, 0.5))))
+	dPosition := Matrix2{XX: dx.X, XY: dy.X, YX: dx.Y, YY: dy.Y}
+
+	coupling := p.Mass / wind.Response
+	return dPosition.Scaled(coupling), Identity2.Scaled(-coupling)
+}
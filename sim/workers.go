@@ -0,0 +1,42 @@
+package sim
+
+import (
+	"runtime"
+	"sync"
+)
+
+// minChunk is the least number of particles given to one worker, smaller chunks cost more to hand
+// out than they save
+const minChunk = 256
+
+// parallel calls work with consecutive chunks [start, end) covering n particles. Chunks are run by
+// at most workers goroutines at once, GOMAXPROCS of them when workers is zero. Every particle is
+// in exactly one chunk, so work which changes only particles of its chunk gives the same results
+// for any number of workers
+func parallel(n int, workers int, work func(start int, end int)) {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if chunks := (n + minChunk - 1) / minChunk; workers > chunks {
+		workers = chunks
+	}
+	if workers <= 1 {
+		work(0, n)
+		return
+	}
+
+	var wg sync.WaitGroup
+	chunk := (n + workers - 1) / workers
+	for start := 0; start < n; start += chunk {
+		end := start + chunk
+		if end > n {
+			end = n
+		}
+		wg.Add(1)
+		go func(start int, end int) {
+			defer wg.Done()
+			work(start, end)
+		}(start, end)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,82 @@
+package sim
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/faiface/pixel"
+)
+
+// TestUchunks tests that chunks of workers cover every particle exactly once
+func TestUchunks(t *testing.T) {
+	for _, n := range []int{0, 1, minChunk - 1, minChunk + 1, 10 * minChunk, 10000} {
+		for _, workers := range []int{0, 1, 2, 3, 7, 64} {
+			visits := make([]int, n)
+			parallel(n, workers, func(start int, end int) {
+				for i := start; i < end; i++ {
+					visits[i]++
+				}
+			})
+			for i, count := range visits {
+				if count != 1 {
+					t.Fatalf("Chunks n=%d workers=%d: Expected particle %d visited once got %d",
+						n, workers, i, count)
+				}
+			}
+		}
+	}
+}
+
+// TestUdeterministic tests particles advanced by different numbers of workers end in the same
+// state bit for bit and count the same substeps
+func TestUdeterministic(t *testing.T) {
+	bounds := pixel.R(0, 0, 800, 600)
+	stokes := StokesDrag
+	simulate := func(workers int) ([]Particle, int, int) {
+		particleSystem := &ParticleSystem{Workers: workers}
+		particleSystem.AddForce(UniformGravity{Acceleration: Gravity})
+		particleSystem.AddForce(Drag{Mode: &stokes, Viscosity: &Parameter{Value: 0.5}})
+
+		random := rand.New(rand.NewSource(1))
+		particles := particleSystem.Particles()
+		for i := 0; i < 2000; i++ {
+			particles.Add(Particle{
+				Position: pixel.V(random.Float64()*800, 300+random.Float64()*300),
+				Speed:    pixel.V(random.NormFloat64(), random.NormFloat64()).Scaled(3),
+				Mass:     0.05,
+				Radius:   ParticleRadius,
+				Lifespan: 100,
+			})
+		}
+
+		scene := NewPegScene(bounds, CapsuleShape)
+		integrator := &DormandPrinceIntegrator{absTolerance: 1e-4, relTolerance: 1e-4}
+		collisions := &ParticleCollisions{Enabled: true, Restitution: &Parameter{Value: 0.5}}
+		boundary := &Boundary{Mode: BoxBoundary, Bounds: bounds, Restitution: &Parameter{Value: 0.5}}
+		for step := 0; step < 50; step++ {
+			particleSystem.Update(0.005, integrator, scene, collisions, &Fluid{}, boundary)
+		}
+		// forces of different systems differ by address only
+		result := particles.slice()
+		for i := range result {
+			result[i].Forces = nil
+		}
+		accepted, rejected := integrator.StepCount()
+		return result, accepted, rejected
+	}
+
+	expected, eAccepted, eRejected := simulate(1)
+	for _, workers := range []int{0, 2, 3, 7} {
+		result, accepted, rejected := simulate(workers)
+		if accepted != eAccepted || rejected != eRejected {
+			t.Errorf("Workers %d: Expected %d accepted and %d rejected substeps got %d and %d",
+				workers, eAccepted, eRejected, accepted, rejected)
+		}
+		for i := range expected {
+			if result[i] != expected[i] {
+				t.Fatalf("Workers %d particle %d: Expected position of %v got %v", workers, i,
+					expected[i].Position, result[i].Position)
+			}
+		}
+	}
+}
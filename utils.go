@@ -3,11 +3,9 @@ package main
 import (
 	"bytes"
 	"image"
-	"image/color"
 	_ "image/png"
 
 	"github.com/faiface/pixel"
-	"github.com/faiface/pixel/imdraw"
 	"github.com/golang/freetype/truetype"
 	"golang.org/x/image/font"
 )
@@ -37,19 +35,6 @@ func isInsideBoundingBox(x, y float64, box pixel.Rect, boxPosition pixel.Vec) bo
 	return x > x0+x1 && x < x0+x2 && y > y0+y1 && y < y0+y2
 }
 
-func (circle *Circle) isPositionInside(position pixel.Vec) bool {
-	return circle.position.To(position).Len() <= circle.radius
-}
-
-func (circle *Circle) draw(imd *imdraw.IMDraw, position pixel.Vec) {
-	imd.Color = color.RGBA{0, 0, 0, 30}
-	imd.Push(position)
-	imd.Circle(circle.radius, 0)
-	imd.Color = color.RGBA{0, 0, 0, 50}
-	imd.Push(position)
-	imd.Circle(circle.radius, 1)
-}
-
 func loadTTF(path string, size float64) (font.Face, error) {
 	file, err := Asset(path)
 	if err != nil {